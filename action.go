@@ -0,0 +1,267 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	placeholderName  *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	placeholderBound *regexp.Regexp = regexp.MustCompile(`^(<=|>=)(-?[0-9]+)`)
+)
+
+// Action is a named command line. Template is split on whitespace, its first
+// word is the program, and every "{name:type}" placeholder is filled from a
+// client parameter. Types are int with optional "<=N" and ">=N" bounds,
+// enum(a|b), string with an optional (pattern), duration, file, directory, and
+// path. A bare "{name}" is a string.
+type Action struct {
+	Template   string
+	Executable *Executable
+}
+
+type segment struct {
+	text      string
+	parameter *Parameter
+}
+
+func (a *Action) parse() (string, [][]*segment, error) {
+	words := strings.Fields(a.Template)
+	if len(words) < 1 || strings.ContainsAny(words[0], "{}") {
+		return "", nil, errTemplateInvalid
+	}
+
+	parsed := [][]*segment{}
+	for _, v := range words[1:] {
+		segments := []*segment{}
+
+		for v != "" {
+			start := strings.IndexAny(v, "{}")
+			if start < 0 {
+				segments = append(segments, &segment{text: v})
+				break
+			}
+
+			if v[start] == '}' {
+				return "", nil, errTemplateInvalid
+			}
+
+			if start > 0 {
+				segments = append(segments, &segment{text: v[:start]})
+			}
+
+			end, depth := start, 0
+			for ; end < len(v); end++ {
+				if v[end] == '{' {
+					depth++
+				}
+
+				if v[end] == '}' {
+					depth--
+				}
+
+				if depth == 0 {
+					break
+				}
+			}
+
+			if depth != 0 {
+				return "", nil, errTemplateInvalid
+			}
+
+			parameter, err := placeholder(v[start+1 : end])
+			if err != nil {
+				return "", nil, err
+			}
+
+			segments = append(segments, &segment{parameter: parameter})
+			v = v[end+1:]
+		}
+
+		parsed = append(parsed, segments)
+	}
+
+	return words[0], parsed, nil
+}
+
+func (a *Action) parameters() ([]*Parameter, error) {
+	_, words, err := a.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []*Parameter{}
+	for _, v := range words {
+		for _, s := range v {
+			if s.parameter == nil {
+				continue
+			}
+
+			index := slices.IndexFunc(parameters, func(p *Parameter) bool {
+				return p.Name == s.parameter.Name
+			})
+
+			if index < 0 {
+				parameters = append(parameters, s.parameter)
+				continue
+			}
+
+			if parameters[index].Type != s.parameter.Type {
+				return nil, errTemplateInvalid
+			}
+		}
+	}
+
+	return parameters, nil
+}
+
+func (h *Handler) expand(a *Action, q []string, x *Executable) (string, []string, error) {
+	program, words, err := a.parse()
+	if err != nil {
+		return "", nil, err
+	}
+
+	parameters, err := a.parameters()
+	if err != nil {
+		return "", nil, err
+	}
+
+	values := map[string]string{}
+	for _, v := range q {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return "", nil, invalid("parameter %q must be given as name=value", v)
+		}
+
+		if !slices.ContainsFunc(parameters, func(p *Parameter) bool {
+			return p.Name == name
+		}) {
+			return "", nil, invalid("parameter %q is unknown", name)
+		}
+
+		_, ok = values[name]
+		if ok {
+			return "", nil, invalid("parameter %q is given more than once", name)
+		}
+
+		values[name] = value
+	}
+
+	for _, v := range parameters {
+		_, ok := values[v.Name]
+		if !ok {
+			return "", nil, invalid("parameter %q is missing", v.Name)
+		}
+	}
+
+	arguments := []string{}
+	for _, v := range words {
+		builder := strings.Builder{}
+
+		for _, s := range v {
+			if s.parameter == nil {
+				builder.WriteString(s.text)
+				continue
+			}
+
+			value, err := h.value(s.parameter, values[s.parameter.Name], x)
+			if err != nil {
+				return "", nil, err
+			}
+
+			builder.WriteString(value)
+		}
+
+		arguments = append(arguments, builder.String())
+	}
+
+	return program, arguments, nil
+}
+
+func placeholder(s string) (*Parameter, error) {
+	name, spec, _ := strings.Cut(s, ":")
+	if !placeholderName.MatchString(name) {
+		return nil, errTemplateInvalid
+	}
+
+	end := strings.IndexFunc(spec, func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+
+	if end < 0 {
+		end = len(spec)
+	}
+
+	parameter := &Parameter{
+		Name: name,
+		Type: spec[:end],
+	}
+
+	if parameter.Type == "" {
+		parameter.Type = typeString
+	}
+
+	rest := spec[end:]
+
+	switch parameter.Type {
+	case typeInt:
+		for rest != "" {
+			match := placeholderBound.FindStringSubmatch(rest)
+			if match == nil {
+				return nil, errTemplateInvalid
+			}
+
+			bound, err := strconv.ParseInt(match[2], 10, 64)
+			if err != nil {
+				return nil, errTemplateInvalid
+			}
+
+			if match[1] == "<=" {
+				parameter.Maximum = &bound
+			} else {
+				parameter.Minimum = &bound
+			}
+
+			rest = rest[len(match[0]):]
+		}
+	case typeEnum, typeString:
+		if rest != "" {
+			if rest[0] != '(' || rest[len(rest)-1] != ')' {
+				return nil, errTemplateInvalid
+			}
+
+			if parameter.Type == typeEnum {
+				parameter.Values = strings.Split(rest[1:len(rest)-1], "|")
+			} else {
+				parameter.Pattern = rest[1 : len(rest)-1]
+			}
+		}
+	default:
+		if rest != "" {
+			return nil, errTemplateInvalid
+		}
+	}
+
+	err := parameter.check()
+	if err != nil {
+		return nil, errTemplateInvalid
+	}
+
+	return parameter, nil
+}
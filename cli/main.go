@@ -20,9 +20,11 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 
+	"github.com/enindu/httpsh"
 	"github.com/spf13/viper"
 )
 
@@ -32,6 +34,7 @@ var (
 )
 
 func main() {
+	httpsh.Init()
 	flag.Parse()
 
 	log := slog.Default()
@@ -106,6 +109,18 @@ func main() {
 			return
 		}
 	case *server:
+		executables, err := executables("server.executables")
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+
+		actions, err := actions("server.actions")
+		if err != nil {
+			log.Error(err.Error())
+			return
+		}
+
 		server := &Server{
 			network:           viper.GetString("server.network"),
 			host:              viper.GetString("server.host"),
@@ -120,11 +135,31 @@ func main() {
 			directory:         viper.GetString("server.directory"),
 			mime:              viper.GetString("server.mime"),
 			methods:           viper.GetStringSlice("server.methods"),
-			executables:       viper.GetStringMapStringSlice("server.executables"),
+			executables:       executables,
+			actions:           actions,
+			timeout:           viper.GetInt("server.timeout"),
+			limit:             limit("server.limit"),
+			exit:              viper.GetString("server.exit"),
+			cgroup:            viper.GetString("server.cgroup"),
+			credential:        credential("server"),
+			policy:            policy("server.policy"),
+			origins:           viper.GetStringSlice("server.origins"),
+			poolSize:          viper.GetInt("server.pool.size"),
+			poolQueue:         viper.GetInt("server.pool.queue"),
+			poolTimeout:       viper.GetInt("server.pool.timeout"),
+			poolRetry:         viper.GetInt("server.pool.retry"),
+			jobsDirectory:     viper.GetString("server.jobs.directory"),
+			jobsTimeout:       viper.GetInt("server.jobs.timeout"),
+			jobsRetention:     viper.GetInt("server.jobs.retention"),
+			jobsLimit:         viper.GetInt("server.jobs.limit"),
+			historyFile:       viper.GetString("server.history.file"),
+			historyAge:        viper.GetInt("server.history.age"),
+			historySize:       viper.GetInt64("server.history.size"),
+			historyOutput:     viper.GetBool("server.history.output"),
 			log:               log,
 		}
 
-		err := server.run()
+		err = server.run()
 		if err != nil {
 			log.Error(err.Error())
 			return
@@ -134,6 +169,146 @@ func main() {
 	}
 }
 
+func executables(k string) (map[string]*httpsh.Executable, error) {
+	executables := map[string]*httpsh.Executable{}
+	for name, v := range viper.GetStringMap(k) {
+		prefix := k + "." + name
+
+		switch v.(type) {
+		case []any:
+			executables[name] = &httpsh.Executable{
+				Options: viper.GetStringSlice(prefix),
+			}
+		case map[string]any:
+			executable, err := executable(prefix)
+			if err != nil {
+				return nil, err
+			}
+
+			executables[name] = executable
+		default:
+			return nil, fmt.Errorf("%s must be a table or a list of options", prefix)
+		}
+	}
+
+	return executables, nil
+}
+
+func actions(k string) (map[string]*httpsh.Action, error) {
+	actions := map[string]*httpsh.Action{}
+	for name, v := range viper.GetStringMap(k) {
+		template, ok := v.(string)
+		if ok {
+			actions[name] = &httpsh.Action{
+				Template:   template,
+				Executable: &httpsh.Executable{},
+			}
+
+			continue
+		}
+
+		prefix := k + "." + name
+
+		executable, err := executable(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		actions[name] = &httpsh.Action{
+			Template:   viper.GetString(prefix + ".template"),
+			Executable: executable,
+		}
+	}
+
+	return actions, nil
+}
+
+func executable(k string) (*httpsh.Executable, error) {
+	parameters, err := parameters(k + ".parameters")
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpsh.Executable{
+		Options:  viper.GetStringSlice(k + ".options"),
+		Shell:    viper.GetBool(k + ".shell"),
+		Timeout:  viper.GetInt(k + ".timeout"),
+		Limit:    limit(k + ".limit"),
+		Stdin:    viper.GetBool(k + ".stdin"),
+		Body:     viper.GetInt64(k + ".body"),
+		Terminal: viper.GetBool(k + ".terminal"),
+		Resources: httpsh.Resources{
+			CPU:       viper.GetUint64(k + ".resources.cpu"),
+			Memory:    viper.GetUint64(k + ".resources.memory"),
+			File:      viper.GetUint64(k + ".resources.file"),
+			Files:     viper.GetUint64(k + ".resources.files"),
+			Processes: viper.GetUint64(k + ".resources.processes"),
+		},
+		Cgroup: httpsh.Cgroup{
+			Memory: viper.GetInt64(k + ".cgroup.memory"),
+			CPU:    viper.GetInt(k + ".cgroup.cpu"),
+		},
+		Credential: credential(k),
+		Landlock: httpsh.Landlock{
+			Enabled: viper.GetBool(k + ".landlock.enabled"),
+			Write:   viper.GetBool(k + ".landlock.write"),
+			Paths:   viper.GetStringSlice(k + ".landlock.paths"),
+		},
+		Seccomp: viper.GetStringSlice(k + ".seccomp"),
+		Namespace: httpsh.Namespace{
+			Enabled:  viper.GetBool(k + ".namespace.enabled"),
+			ReadOnly: viper.GetBool(k + ".namespace.read_only"),
+		},
+		Paths:      viper.GetStringSlice(k + ".paths"),
+		Parameters: parameters,
+		Text: httpsh.Text{
+			Enabled: viper.GetBool(k + ".text.enabled"),
+			Length:  viper.GetInt(k + ".text.length"),
+			Classes: viper.GetStringSlice(k + ".text.classes"),
+		},
+		Glob: httpsh.Glob{
+			Limit: viper.GetInt(k + ".glob.limit"),
+			Empty: viper.GetBool(k + ".glob.empty"),
+		},
+	}, nil
+}
+
+func limit(k string) httpsh.Limit {
+	return httpsh.Limit{
+		Stdout: viper.GetInt64(k + ".stdout"),
+		Stderr: viper.GetInt64(k + ".stderr"),
+		Action: viper.GetString(k + ".action"),
+		Spill:  viper.GetInt64(k + ".spill"),
+	}
+}
+
+func parameters(k string) ([]*httpsh.Parameter, error) {
+	parameters := []*httpsh.Parameter{}
+
+	err := viper.UnmarshalKey(k, &parameters)
+	if err != nil {
+		return nil, fmt.Errorf("%s is invalid: %w", k, err)
+	}
+
+	return parameters, nil
+}
+
+func policy(k string) httpsh.Policy {
+	return httpsh.Policy{
+		Deny:     viper.GetStringSlice(k + ".deny"),
+		Hidden:   viper.GetBool(k + ".hidden"),
+		Symlinks: viper.GetString(k + ".symlinks"),
+	}
+}
+
+func credential(k string) httpsh.Credential {
+	return httpsh.Credential{
+		User:   viper.GetString(k + ".user"),
+		Group:  viper.GetString(k + ".group"),
+		Groups: viper.GetStringSlice(k + ".groups"),
+	}
+}
+
 func certificate(f string, s *x509.Certificate, c *x509.Certificate, public *rsa.PublicKey, private *rsa.PrivateKey) (*x509.Certificate, error) {
 	certificate, err := x509.CreateCertificate(rand.Reader, s, c, public, private)
 	if err != nil {
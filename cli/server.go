@@ -37,7 +37,27 @@ type Server struct {
 	directory         string
 	mime              string
 	methods           []string
-	executables       map[string][]string
+	executables       map[string]*httpsh.Executable
+	actions           map[string]*httpsh.Action
+	timeout           int
+	limit             httpsh.Limit
+	exit              string
+	cgroup            string
+	credential        httpsh.Credential
+	policy            httpsh.Policy
+	origins           []string
+	poolSize          int
+	poolQueue         int
+	poolTimeout       int
+	poolRetry         int
+	jobsDirectory     string
+	jobsTimeout       int
+	jobsRetention     int
+	jobsLimit         int
+	historyFile       string
+	historyAge        int
+	historySize       int64
+	historyOutput     bool
 	log               *slog.Logger
 }
 
@@ -56,11 +76,46 @@ func (s *Server) run() error {
 
 	defer listener.Close()
 
+	workers := &httpsh.Pool{
+		Size:    s.poolSize,
+		Queue:   s.poolQueue,
+		Timeout: s.poolTimeout,
+		Retry:   s.poolRetry,
+	}
+
+	jobs := &httpsh.Jobs{
+		Directory: s.jobsDirectory,
+		Timeout:   s.jobsTimeout,
+		Retention: s.jobsRetention,
+		Limit:     s.jobsLimit,
+	}
+
+	var history *httpsh.History
+	if s.historyFile != "" {
+		history = &httpsh.History{
+			File:   s.historyFile,
+			Age:    s.historyAge,
+			Size:   s.historySize,
+			Output: s.historyOutput,
+		}
+	}
+
 	handler := &httpsh.Handler{
 		Directory:   s.directory,
 		Mime:        s.mime,
 		Methods:     s.methods,
 		Executables: s.executables,
+		Actions:     s.actions,
+		Timeout:     s.timeout,
+		Limit:       s.limit,
+		Exit:        s.exit,
+		Cgroup:      s.cgroup,
+		Credential:  s.credential,
+		Policy:      s.policy,
+		Origins:     s.origins,
+		Pool:        workers,
+		Jobs:        jobs,
+		History:     history,
 		Log:         s.log,
 	}
 
@@ -0,0 +1,307 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type Command struct {
+	name       string
+	action     string
+	arguments  []string
+	executable *Executable
+	directory  string
+	timeout    time.Duration
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     *Output
+	terminal   *os.File
+	cgroup     string
+	credential *syscall.Credential
+	leaf       string
+	status     *os.File
+	argv       []string
+	started    time.Time
+	ended      time.Time
+	state      *os.ProcessState
+	process    *exec.Cmd
+	context    context.Context
+	cancel     context.CancelFunc
+}
+
+func (c *Command) run(x context.Context) error {
+	err := c.start(x)
+	if err != nil {
+		return err
+	}
+
+	return c.wait()
+}
+
+func (c *Command) start(x context.Context) error {
+	if c.timeout > 0 {
+		c.context, c.cancel = context.WithTimeout(x, c.timeout)
+	} else {
+		c.context, c.cancel = context.WithCancel(x)
+	}
+
+	command := exec.CommandContext(c.context, c.name, c.arguments...)
+	if c.executable.Shell {
+		command = exec.CommandContext(c.context, "sh", "-c", c.line())
+	}
+
+	command.Dir = c.directory
+	command.Stdin = c.stdin
+	command.Stdout = c.stdout
+	command.Stderr = c.stderr
+	command.Cancel = func() error {
+		return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+	}
+	command.WaitDelay = time.Second
+
+	attributes := &syscall.SysProcAttr{Setpgid: true}
+	if c.terminal != nil {
+		command.Stdin = c.terminal
+		command.Stdout = c.terminal
+		command.Stderr = c.terminal
+		attributes = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	}
+
+	attributes.Credential = c.credential
+	c.executable.Namespace.attributes(attributes)
+
+	c.process = command
+	c.argv = command.Args
+	c.started = time.Now()
+
+	if c.cgroup != "" && !c.executable.Cgroup.empty() {
+		leaf, file, err := c.executable.Cgroup.create(c.cgroup)
+		if err != nil {
+			c.ended = time.Now()
+			c.cancel()
+			return err
+		}
+
+		defer file.Close()
+
+		c.leaf = leaf
+
+		err = attach(attributes, file)
+		if err != nil {
+			c.ended = time.Now()
+			c.cancel()
+			remove(c.leaf)
+			return err
+		}
+	}
+
+	command.SysProcAttr = attributes
+
+	directory, err := filepath.Abs(c.directory)
+	if err != nil {
+		directory = c.directory
+	}
+
+	sandbox := &Sandbox{
+		Directory: directory,
+		Resources: c.executable.Resources,
+		Landlock:  c.executable.Landlock,
+		Seccomp:   c.executable.Seccomp,
+		Namespace: c.executable.Namespace,
+	}
+
+	c.status, err = sandbox.wrap(command)
+	if err == nil {
+		err = command.Start()
+	}
+
+	for _, v := range command.ExtraFiles {
+		v.Close()
+	}
+
+	if err != nil {
+		c.ended = time.Now()
+		c.cancel()
+		remove(c.leaf)
+		c.close()
+		return err
+	}
+
+	return nil
+}
+
+func (c *Command) wait() error {
+	defer c.cancel()
+	defer remove(c.leaf)
+
+	defer c.close()
+
+	err := c.process.Wait()
+	c.ended = time.Now()
+	c.state = c.process.ProcessState
+
+	if errors.Is(c.context.Err(), context.DeadlineExceeded) {
+		return errCommandTimeout
+	}
+
+	if err != nil && c.status != nil {
+		message, _ := io.ReadAll(c.status)
+		number, ok := strings.CutPrefix(string(message), sandboxSignal)
+		if ok {
+			signal, _ := strconv.Atoi(strings.TrimSpace(number))
+			return &Killed{signal: syscall.Signal(signal)}
+		}
+
+		if len(message) > 0 {
+			return &Failure{
+				class:   errCommandFailed,
+				message: strings.TrimSpace(string(message)),
+			}
+		}
+	}
+
+	return err
+}
+
+func (c *Command) close() {
+	if c.status != nil {
+		c.status.Close()
+	}
+}
+
+func (c *Command) line() string {
+	buffer := bytes.Buffer{}
+	defer buffer.Reset()
+
+	buffer.WriteString(c.name)
+	buffer.WriteString(" ")
+
+	for _, v := range c.arguments {
+		buffer.WriteString(v)
+		buffer.WriteString(" ")
+	}
+
+	return buffer.String()
+}
+
+type Failure struct {
+	class   error
+	message string
+}
+
+func (f *Failure) Error() string {
+	return f.message
+}
+
+func (f *Failure) Unwrap() error {
+	return f.class
+}
+
+func failure(e error, s string) error {
+	if e == nil || errors.Is(e, errCommandTimeout) || errors.Is(e, errOutputExceeded) || errors.Is(e, errInputExceeded) {
+		return e
+	}
+
+	if errors.As(e, new(*Failure)) {
+		return e
+	}
+
+	signal, ok := killer(e)
+
+	exit := &exec.ExitError{}
+	if !errors.As(e, &exit) && !ok {
+		return &Failure{
+			class:   errCommandFailed,
+			message: e.Error(),
+		}
+	}
+
+	class := errCommandExited
+	if ok {
+		class = errCommandCrashed
+	}
+
+	if ok && signal == syscall.SIGSYS {
+		class = errCommandViolated
+		s = ""
+	}
+
+	if strings.TrimSpace(s) == "" {
+		s = class.Error()
+	}
+
+	return &Failure{
+		class:   class,
+		message: s,
+	}
+}
+
+// Killed is a command killed by a signal as the child of a namespace init,
+// which reports the signal since it can not be killed by it.
+type Killed struct {
+	signal syscall.Signal
+}
+
+func (k *Killed) Error() string {
+	return "signal: " + k.signal.String()
+}
+
+func killer(e error) (syscall.Signal, bool) {
+	killed := &Killed{}
+	if errors.As(e, &killed) {
+		return killed.signal, true
+	}
+
+	exit := &exec.ExitError{}
+	if !errors.As(e, &exit) {
+		return 0, false
+	}
+
+	status, ok := exit.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+
+	return status.Signal(), true
+}
+
+func signaled(e error, s syscall.Signal) bool {
+	signal, ok := killer(e)
+	return ok && signal == s
+}
+
+func status(e error) int {
+	if e == nil {
+		return 0
+	}
+
+	exit := &exec.ExitError{}
+	if errors.As(e, &exit) {
+		return exit.ExitCode()
+	}
+
+	return -1
+}
@@ -0,0 +1,139 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+type Credential struct {
+	User   string
+	Group  string
+	Groups []string
+}
+
+func (c Credential) empty() bool {
+	return c.User == "" && c.Group == "" && len(c.Groups) < 1
+}
+
+func (c Credential) merge(d Credential) Credential {
+	if c.empty() {
+		return d
+	}
+
+	return c
+}
+
+func (c Credential) resolve() (*syscall.Credential, error) {
+	if c.empty() {
+		return nil, nil
+	}
+
+	credential := &syscall.Credential{
+		Uid:    uint32(os.Getuid()),
+		Gid:    uint32(os.Getgid()),
+		Groups: []uint32{},
+	}
+
+	if c.User != "" {
+		account, err := user.Lookup(c.User)
+		if err != nil {
+			account, err = user.LookupId(c.User)
+		}
+
+		switch {
+		case err == nil:
+			credential.Uid, err = id(account.Uid)
+			if err != nil {
+				return nil, err
+			}
+
+			credential.Gid, err = id(account.Gid)
+			if err != nil {
+				return nil, err
+			}
+		case c.Group != "":
+			credential.Uid, err = id(c.User)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errCredentialInvalid
+		}
+	}
+
+	if c.Group != "" {
+		gid, err := group(c.Group)
+		if err != nil {
+			return nil, err
+		}
+
+		credential.Gid = gid
+	}
+
+	for _, v := range c.Groups {
+		gid, err := group(v)
+		if err != nil {
+			return nil, err
+		}
+
+		credential.Groups = append(credential.Groups, gid)
+	}
+
+	credential.NoSetGroups = len(c.Groups) < 1 && !capable(capabilitySetgid)
+	return credential, nil
+}
+
+func (c Credential) check() (*syscall.Credential, error) {
+	credential, err := c.resolve()
+	if err != nil || credential == nil {
+		return nil, err
+	}
+
+	if capable(capabilitySetuid) && capable(capabilitySetgid) {
+		return credential, nil
+	}
+
+	if credential.Uid == uint32(os.Getuid()) && credential.Gid == uint32(os.Getgid()) && len(credential.Groups) < 1 {
+		return credential, nil
+	}
+
+	return nil, errCredentialUnavailable
+}
+
+func group(s string) (uint32, error) {
+	group, err := user.LookupGroup(s)
+	if err != nil {
+		group, err = user.LookupGroupId(s)
+	}
+
+	if err != nil {
+		return id(s)
+	}
+
+	return id(group.Gid)
+}
+
+func id(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, errCredentialInvalid
+	}
+
+	return uint32(id), nil
+}
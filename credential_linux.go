@@ -0,0 +1,51 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	capabilitySetuid int = unix.CAP_SETUID
+	capabilitySetgid int = unix.CAP_SETGID
+)
+
+func capable(c int) bool {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+
+		capabilities, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		return err == nil && capabilities&(1<<uint(c)) != 0
+	}
+
+	return false
+}
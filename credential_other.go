@@ -0,0 +1,30 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package httpsh
+
+import "os"
+
+const (
+	capabilitySetuid int = 7
+	capabilitySetgid int = 6
+)
+
+// capable has no capability sets to read outside Linux, so only root can switch
+// credentials there.
+func capable(c int) bool {
+	return os.Geteuid() == 0
+}
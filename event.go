@@ -0,0 +1,151 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Events struct {
+	writer   io.Writer
+	sequence int
+	size     int64
+	offsets  []int64
+	lines    []*Lines
+	mutex    sync.Mutex
+}
+
+func (e *Events) stream(s string) *Lines {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	lines := &Lines{
+		events: e,
+		stream: s,
+	}
+
+	e.lines = append(e.lines, lines)
+	return lines
+}
+
+func (e *Events) emit(s string, d string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.sequence++
+	e.offsets = append(e.offsets, e.size)
+
+	buffer := bytes.Buffer{}
+	defer buffer.Reset()
+
+	buffer.WriteString("id: " + strconv.Itoa(e.sequence) + "\n")
+	buffer.WriteString("event: " + s + "\n")
+	buffer.WriteString("data: " + strings.ReplaceAll(d, "\r", "") + "\n\n")
+
+	n, err := e.writer.Write(buffer.Bytes())
+	e.size += int64(n)
+
+	return err
+}
+
+func (e *Events) exit(p *Pipeline, c []int, err error) error {
+	for _, v := range e.lines {
+		v.flush()
+	}
+
+	exit := map[string]any{
+		"exit_codes": c,
+	}
+
+	if len(c) == len(p.commands) && len(p.errors) == len(p.commands) {
+		report := report(p, c, err)
+
+		exit["exit_code"] = report.ExitCode
+		exit["duration"] = report.Duration
+		exit["truncated"] = report.Truncated
+		exit["usage"] = p.usage()
+	}
+
+	if err != nil {
+		exit["error"] = err.Error()
+	}
+
+	data, failure := json.Marshal(exit)
+	if failure != nil {
+		return failure
+	}
+
+	return e.emit("exit", string(data))
+}
+
+func (e *Events) offset(i int) int64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if i < 0 {
+		return 0
+	}
+
+	if i < len(e.offsets) {
+		return e.offsets[i]
+	}
+
+	return e.size
+}
+
+type Lines struct {
+	events *Events
+	stream string
+	buffer []byte
+	mutex  sync.Mutex
+}
+
+func (l *Lines) Write(b []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.buffer = append(l.buffer, b...)
+
+	for {
+		index := bytes.IndexByte(l.buffer, '\n')
+		if index < 0 {
+			return len(b), nil
+		}
+
+		err := l.events.emit(l.stream, string(bytes.TrimSuffix(l.buffer[:index], []byte("\r"))))
+		l.buffer = l.buffer[index+1:]
+
+		if err != nil {
+			return len(b), err
+		}
+	}
+}
+
+func (l *Lines) flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.buffer) < 1 {
+		return
+	}
+
+	l.events.emit(l.stream, string(bytes.TrimSuffix(l.buffer, []byte("\r"))))
+	l.buffer = nil
+}
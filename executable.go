@@ -0,0 +1,56 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+type Executable struct {
+	Options    []string
+	Shell      bool
+	Timeout    int
+	Limit      Limit
+	Stdin      bool
+	Body       int64
+	Terminal   bool
+	Resources  Resources
+	Cgroup     Cgroup
+	Credential Credential
+	Landlock   Landlock
+	Seccomp    []string
+	Namespace  Namespace
+	Paths      []string
+	Parameters []*Parameter
+	Text       Text
+	Glob       Glob
+}
+
+func (e *Executable) positionals() []*Parameter {
+	positionals := []*Parameter{}
+	for _, v := range e.Parameters {
+		if v.Option == "" {
+			positionals = append(positionals, v)
+		}
+	}
+
+	return positionals
+}
+
+func (e *Executable) option(s string) *Parameter {
+	for _, v := range e.Parameters {
+		if v.Option != "" && v.Option == s {
+			return v
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	globLimit   int = 1000
+	globEntries int = 100000
+)
+
+// Glob bounds g_ arguments. Limit caps the number of matches and defaults to
+// 1000, and Empty lets a pattern without matches expand to nothing instead of
+// refusing the request.
+type Glob struct {
+	Limit int  `json:"limit,omitempty"`
+	Empty bool `json:"empty,omitempty"`
+}
+
+func (g *Glob) limit() int {
+	if g.Limit > 0 {
+		return g.Limit
+	}
+
+	return globLimit
+}
+
+func (h *Handler) glob(p string, x *Executable) ([]string, error) {
+	matches, err := h.walk(p, x)
+	if err != nil {
+		return nil, err
+	}
+
+	arguments := []string{}
+	for _, v := range matches {
+		if x.Shell {
+			arguments = append(arguments, quote(v[1]))
+			continue
+		}
+
+		arguments = append(arguments, v[1])
+	}
+
+	return arguments, nil
+}
+
+// walk expands a pattern beneath the directory in lexical order, where "**"
+// matches any number of path segments, and returns the relative and the
+// resolved path of each match. Matches that the path policy or the subtrees of
+// the executable refuse are left out.
+func (h *Handler) walk(p string, x *Executable) ([][2]string, error) {
+	if !filepath.IsLocal(p) {
+		return nil, errPathEscaped
+	}
+
+	pattern := strings.Split(filepath.ToSlash(filepath.Clean(p)), "/")
+
+	prefix := 0
+	for i, v := range pattern {
+		_, err := path.Match(v, "")
+		if err != nil {
+			return nil, invalid("pattern %q is invalid", p)
+		}
+
+		if prefix == i && !strings.ContainsAny(v, `*?[\`) {
+			prefix++
+		}
+	}
+
+	recursive := slices.Contains(pattern, "**")
+	limit := x.Glob.limit()
+
+	matches := [][2]string{}
+	entries := 0
+	root := filepath.Join(append([]string{h.Directory}, pattern[:prefix]...)...)
+
+	filepath.WalkDir(root, func(f string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		relative, ok := beneath(h.Directory, f)
+		if !ok || relative == "." {
+			return nil
+		}
+
+		entries++
+		if entries > globEntries {
+			return fs.SkipAll
+		}
+
+		if d.IsDir() && !h.reachable(relative, x) {
+			return fs.SkipDir
+		}
+
+		segments := strings.Split(filepath.ToSlash(relative), "/")
+		if match(pattern, segments) {
+			file, _, err := h.target(relative, x)
+			if err == nil {
+				matches = append(matches, [2]string{relative, h.path(file, x)})
+			}
+		}
+
+		if len(matches) > limit {
+			return fs.SkipAll
+		}
+
+		if d.IsDir() && !recursive && len(segments) >= len(pattern) && f != root {
+			return fs.SkipDir
+		}
+
+		return nil
+	})
+
+	if entries > globEntries {
+		return nil, invalid("pattern %q visits more than %d paths", p, globEntries)
+	}
+
+	if len(matches) > limit {
+		return nil, invalid("pattern %q matches more than %d paths", p, limit)
+	}
+
+	if len(matches) < 1 && !x.Glob.Empty {
+		return nil, &Failure{class: errTargetNotFound, message: "pattern " + strconv.Quote(p) + " matches nothing"}
+	}
+
+	slices.SortFunc(matches, func(a [2]string, b [2]string) int {
+		return strings.Compare(a[0], b[0])
+	})
+
+	return matches, nil
+}
+
+// reachable reports whether a directory may hold matches, that is whether the
+// path policy allows it and it lies on the way to or beneath a subtree of the
+// executable.
+func (h *Handler) reachable(r string, x *Executable) bool {
+	if h.Policy.permit(r) != nil {
+		return false
+	}
+
+	if len(x.Paths) < 1 {
+		return true
+	}
+
+	for _, v := range x.Paths {
+		_, below := beneath(filepath.Clean(v), r)
+		_, above := beneath(r, filepath.Clean(v))
+		if below || above {
+			return true
+		}
+	}
+
+	return false
+}
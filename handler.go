@@ -15,25 +15,45 @@
 package httpsh
 
 import (
-	"bytes"
-	"errors"
+	"context"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
-	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	modeBuffer string = "buffer"
+	modeStream string = "stream"
+	modeJSON   string = "json"
+	modeEvent  string = "event"
 )
 
 type Handler struct {
 	Directory   string
 	Mime        string
 	Methods     []string
-	Executables map[string][]string
+	Executables map[string]*Executable
+	Actions     map[string]*Action
+	Timeout     int
+	Limit       Limit
+	Exit        string
+	Cgroup      string
+	Credential  Credential
+	Policy      Policy
+	Origins     []string
+	Pool        *Pool
+	Jobs        *Jobs
+	History     *History
 	Log         *slog.Logger
-	mutex       sync.Mutex
+	credentials map[*Executable]*syscall.Credential
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -42,161 +62,755 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		request: r,
 		mime:    h.Mime,
 		methods: h.Methods,
+		exit:    h.Exit,
 		log:     h.Log,
 	}
 
-	err := os.Chdir(h.Directory)
-	if err != nil {
-		response.error(http.StatusBadRequest, errChangeDirectory)
+	if h.Pool != nil {
+		response.retry = h.Pool.Retry
+	}
+
+	info, err := os.Stat(h.Directory)
+	if err != nil || !info.IsDir() {
+		response.error(errChangeDirectory)
 		return
 	}
 
 	if !slices.Contains(h.Methods, r.Method) {
-		response.error(http.StatusMethodNotAllowed, errMethodNotAllowed)
+		response.error(errMethodNotAllowed)
 		return
 	}
 
-	if r.URL.Path != "/" {
-		response.error(http.StatusForbidden, errAccessDenied)
-		return
+	switch {
+	case r.URL.Path == "/":
+		h.execute(response, r)
+	case r.URL.Path == "/help":
+		h.help(response)
+	case r.URL.Path == "/jobs" || strings.HasPrefix(r.URL.Path, "/jobs/"):
+		h.jobs(response, r)
+	case r.URL.Path == "/history":
+		h.history(response, r)
+	case r.URL.Path == "/terminal":
+		h.terminal(response, r)
+	default:
+		response.error(errAccessDenied)
 	}
+}
 
+func (h *Handler) execute(response *Response, r *http.Request) {
 	queries := r.URL.Query()
 	if len(queries) < 1 {
-		response.error(http.StatusBadRequest, errQueryInvalid)
+		response.error(errQueryInvalid)
 		return
 	}
 
-	executable, options, err := h.program(queries)
+	mode, err := h.mode(queries, r.Header)
 	if err != nil {
-		response.error(http.StatusBadRequest, err)
+		response.error(err)
 		return
 	}
 
-	arguments, err := h.arguments(queries, options)
+	response.mode = mode
+
+	commands, err := h.stages(queries)
 	if err != nil {
-		response.error(http.StatusBadRequest, err)
+		response.error(err)
 		return
 	}
 
-	line := h.line(executable, arguments)
-	stdout, stderr, err := h.command(line)
+	stdin, err := h.stdin(r, commands[0].executable)
 	if err != nil {
-		response.error(http.StatusBadRequest, errors.New(stderr))
+		response.error(err)
 		return
 	}
 
-	response.write(http.StatusOK, stdout)
+	pipeline := h.pipeline(commands, stdin)
+	defer pipeline.close()
+
+	err = h.Pool.acquire(r.Context())
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	defer h.Pool.release()
+
+	var codes []int
+
+	switch mode {
+	case modeStream:
+		codes, err = h.stream(response, pipeline, r.Context())
+	case modeJSON:
+		codes, err = h.json(response, pipeline, r.Context())
+	case modeEvent:
+		codes, err = h.event(response, pipeline, r.Context())
+	default:
+		codes, err = h.buffer(response, pipeline, r.Context())
+	}
+
+	h.record(identity(r), subject(r), "", pipeline, codes, err)
 }
 
-func (h *Handler) command(l string) (string, string, error) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+func (h *Handler) buffer(r *Response, p *Pipeline, x context.Context) ([]int, error) {
+	p.run(x)
+
+	codes, err := p.result()
+	r.code(codes...)
+	r.usage(p.usage())
+
+	if err != nil && r.status(err) != http.StatusOK {
+		r.error(err)
+		return codes, err
+	}
+
+	reader, failure := p.stdout.reader()
+	if failure != nil {
+		r.error(errOutputUnreadable)
+		return codes, err
+	}
+
+	if err != nil {
+		r.failed(err)
+	}
+
+	r.truncate(p.truncated())
+	r.send(http.StatusOK, reader)
+	return codes, err
+}
+
+func (h *Handler) stream(r *Response, p *Pipeline, x context.Context) ([]int, error) {
+	writer, err := r.stream()
+	if err != nil {
+		r.error(err)
+		return nil, err
+	}
 
-	stdout := &bytes.Buffer{}
-	defer stdout.Reset()
+	p.stdout.writer = writer
+	p.stdout.capture = h.History != nil && h.History.Output
+	p.run(x)
 
-	stderr := &bytes.Buffer{}
-	defer stderr.Reset()
+	codes, err := p.result()
 
-	command := exec.Command("sh", "-c", l)
-	command.Stdout = stdout
-	command.Stderr = stderr
+	r.truncate(p.truncated())
+	r.usage(p.usage())
+	r.finish(codes, err)
+	return codes, err
+}
 
-	err := command.Run()
+func (h *Handler) event(r *Response, p *Pipeline, x context.Context) ([]int, error) {
+	writer, err := r.events()
 	if err != nil {
-		return "", stderr.String(), err
+		r.error(err)
+		return nil, err
+	}
+
+	events := &Events{
+		writer: writer,
+	}
+
+	p.stdout.writer = events.stream("stdout")
+	p.stdout.capture = h.History != nil && h.History.Output
+
+	for _, v := range p.commands {
+		v.stderr.writer = events.stream("stderr")
+		v.stderr.capture = true
 	}
 
-	return stdout.String(), "", nil
+	p.run(x)
+
+	codes, err := p.result()
+
+	events.exit(p, codes, err)
+	return codes, err
 }
 
-func (h *Handler) line(e string, a []string) string {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+func (h *Handler) json(r *Response, p *Pipeline, x context.Context) ([]int, error) {
+	p.run(x)
 
-	buffer := bytes.Buffer{}
-	defer buffer.Reset()
+	codes, err := p.result()
+	report := report(p, codes, err)
 
-	buffer.WriteString(e)
-	buffer.WriteString(" ")
+	reader, failure := p.stdout.reader()
+	if failure != nil {
+		r.error(errOutputUnreadable)
+		return codes, err
+	}
+
+	// A spill file is not read into memory beyond the stdout limit.
+	if p.stdout.file != nil && p.stdout.limit > 0 {
+		reader = io.LimitReader(reader, p.stdout.limit)
+		report.Truncated = append(report.Truncated, "stdout")
+	}
 
-	for _, v := range a {
-		buffer.WriteString(v)
-		buffer.WriteString(" ")
+	output, failure := io.ReadAll(reader)
+	if failure != nil {
+		r.error(errOutputUnreadable)
+		return codes, err
+	}
+
+	report.Stdout = string(output)
+
+	if err != nil {
+		r.json(r.status(err), report)
+		return codes, err
 	}
 
-	return buffer.String()
+	r.json(http.StatusOK, report)
+	return codes, err
 }
 
-func (h *Handler) arguments(q map[string][]string, o []string) (a []string, e error) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+func (h *Handler) help(r *Response) {
+	executables := map[string]any{}
+	for k, v := range h.Executables {
+		limit := h.limit(v)
+		credential := v.Credential.merge(h.Credential)
+
+		executables[k] = map[string]any{
+			"options":    v.Options,
+			"shell":      v.Shell,
+			"timeout":    h.timeout(v).Seconds(),
+			"stdin":      v.Stdin,
+			"body":       v.Body,
+			"terminal":   v.Terminal,
+			"resources":  v.Resources,
+			"cgroup":     v.Cgroup,
+			"user":       credential.User,
+			"group":      credential.Group,
+			"groups":     credential.Groups,
+			"landlock":   v.Landlock,
+			"seccomp":    v.Seccomp,
+			"namespace":  v.Namespace,
+			"paths":      v.Paths,
+			"parameters": v.Parameters,
+			"text":       v.Text,
+			"glob":       v.Glob,
+			"limit": map[string]any{
+				"stdout": limit.Stdout,
+				"stderr": limit.Stderr,
+				"action": limit.Action,
+				"spill":  limit.Spill,
+			},
+		}
+	}
 
-	if len(q["a"]) > 0 {
-		for _, v := range q["a"] {
-			if len(v) < 3 {
-				return nil, errArgumentsInvalid
-			}
+	actions := map[string]any{}
+	for k, v := range h.Actions {
+		parameters, _ := v.parameters()
+		actions[k] = map[string]any{
+			"parameters": parameters,
+		}
+	}
 
-			switch v[:2] {
-			case "d_":
-				directory := filepath.Join(h.Directory, v[2:])
-				info, err := os.Stat(directory)
-				if err != nil {
-					return nil, errTargetNotFound
-				}
+	r.json(http.StatusOK, map[string]any{
+		"executables": executables,
+		"actions":     actions,
+	})
+}
 
-				if !info.IsDir() {
-					return nil, errTargetNotDirectory
-				}
+func (h *Handler) check() error {
+	credential, err := h.Credential.check()
+	if err != nil {
+		h.Log.Error(err.Error(), "user", h.Credential.User, "group", h.Credential.Group)
+		return err
+	}
 
-				a = append(a, directory)
-			case "f_":
-				file := filepath.Join(h.Directory, v[2:])
-				info, err := os.Stat(file)
-				if err != nil {
-					return nil, errTargetNotFound
-				}
+	h.credentials = map[*Executable]*syscall.Credential{nil: credential}
 
-				if info.IsDir() {
-					return nil, errTargetNotFile
-				}
+	if h.Exit != "" && h.Exit != exitHeader && h.Exit != exitUnprocessable {
+		h.Log.Error(errExitInvalid.Error(), "exit", h.Exit)
+		return errExitInvalid
+	}
 
-				a = append(a, file)
-			case "o_":
-				if !slices.Contains(o, v[2:]) {
-					return nil, errOptionNotFound
-				}
+	err = h.Policy.check()
+	if err != nil {
+		h.Log.Error(err.Error(), "deny", h.Policy.Deny, "symlinks", h.Policy.Symlinks)
+		return err
+	}
 
-				a = append(a, v[2:])
-			case "t_":
-				if !strings.HasPrefix(v[2:], "'") || !strings.HasSuffix(v[2:], "'") {
-					return nil, errTextInvalid
-				}
+	err = h.Limit.check()
+	if err != nil {
+		h.Log.Error(err.Error(), "action", h.Limit.Action)
+		return err
+	}
 
+	for k, v := range h.Executables {
+		err := h.inspect(v, h.Log.With("executable", k))
+		if err != nil {
+			return err
+		}
+	}
+
+	for k, v := range h.Actions {
+		_, err := v.parameters()
+		if err != nil {
+			h.Log.Error(err.Error(), "action", k, "template", v.Template)
+			return err
+		}
+
+		if v.Executable == nil {
+			continue
+		}
+
+		err = h.inspect(v.Executable, h.Log.With("action", k))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) inspect(x *Executable, l *slog.Logger) error {
+	credential, err := x.Credential.check()
+	if err != nil {
+		l.Error(err.Error(), "user", x.Credential.User, "group", x.Credential.Group)
+		return err
+	}
+
+	if !x.Credential.empty() {
+		h.credentials[x] = credential
+	}
+
+	err = x.Limit.check()
+	if err != nil {
+		l.Error(err.Error(), "action", x.Limit.Action)
+		return err
+	}
+
+	err = seccomp(x.Seccomp)
+	if err != nil {
+		l.Error(err.Error(), "seccomp", x.Seccomp)
+		return err
+	}
+
+	err = x.Namespace.check()
+	if err != nil {
+		l.Error(err.Error(), "namespace", x.Namespace.Enabled)
+		return err
+	}
+
+	err = x.Resources.check()
+	if err != nil {
+		l.Error(err.Error(), "resources", x.Resources)
+		return err
+	}
+
+	if x.Shell && slices.Contains(x.Seccomp, seccompNoExec) {
+		l.Error(errSeccompInvalid.Error(), "seccomp", x.Seccomp, "shell", x.Shell)
+		return errSeccompInvalid
+	}
+
+	err = x.Text.check()
+	if err != nil {
+		l.Error(err.Error(), "classes", x.Text.Classes)
+		return err
+	}
+
+	for _, p := range x.Parameters {
+		err := p.check()
+		if err != nil {
+			l.Error(err.Error(), "parameter", p.label())
+			return err
+		}
+	}
+
+	_, landlock := landlock()
+	if x.Landlock.Enabled && landlock != nil {
+		l.Warn("landlock is not supported, command runs unconfined", "error", landlock.Error())
+	}
+
+	return nil
+}
+
+func (h *Handler) stages(q map[string][]string) ([]*Command, error) {
+	keys := [][3]string{{"e", "a", "p"}}
+	if len(q["e"]) < 1 && len(q["e1"]) > 0 {
+		keys = [][3]string{}
+		for i := 1; len(q["e"+strconv.Itoa(i)]) > 0; i++ {
+			keys = append(keys, [3]string{"e" + strconv.Itoa(i), "a" + strconv.Itoa(i), "p" + strconv.Itoa(i)})
+		}
+	}
+
+	for k := range q {
+		if len(k) < 2 || (k[0] != 'e' && k[0] != 'a' && k[0] != 'p') {
+			continue
+		}
+
+		index, err := strconv.Atoi(k[1:])
+		if err != nil {
+			continue
+		}
+
+		if len(keys) == 1 && keys[0][0] == "e" || index < 1 || index > len(keys) {
+			return nil, errPipelineInvalid
+		}
+	}
+
+	commands := []*Command{}
+	for _, v := range keys {
+		name, executable, action, err := h.program(q[v[0]])
+		if err != nil {
+			return nil, err
+		}
+
+		program, label, arguments := name, "", []string{}
+		switch {
+		case action != nil && len(q[v[1]]) > 0:
+			return nil, invalid("action %q takes parameters with %s", name, v[2])
+		case action != nil:
+			label = name
+			program, arguments, err = h.expand(action, q[v[2]], executable)
+		case len(q[v[2]]) > 0:
+			return nil, invalid("executable %q takes arguments with %s", name, v[1])
+		default:
+			arguments, err = h.arguments(q[v[1]], executable)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		credential, err := h.credential(executable)
+		if err != nil {
+			return nil, err
+		}
+
+		err = seccomp(executable.Seccomp)
+		if err != nil {
+			return nil, err
+		}
+
+		commands = append(commands, &Command{
+			name:       program,
+			action:     label,
+			executable: executable,
+			arguments:  arguments,
+			directory:  h.Directory,
+			timeout:    h.timeout(executable),
+			cgroup:     h.Cgroup,
+			credential: credential,
+		})
+	}
+
+	return commands, nil
+}
+
+func (h *Handler) pipeline(c []*Command, i *Input) *Pipeline {
+	limit := h.limit(c[len(c)-1].executable)
+
+	pipeline := &Pipeline{
+		commands: c,
+		stdin:    i,
+		stdout: &Output{
+			limit:   limit.Stdout,
+			action:  limit.Action,
+			maximum: limit.Spill,
+		},
+	}
+
+	for _, v := range c {
+		limit := h.limit(v.executable)
+
+		v.stderr = &Output{
+			limit:   limit.Stderr,
+			action:  limit.Action,
+			maximum: limit.Spill,
+		}
+	}
+
+	return pipeline
+}
+
+func (h *Handler) limit(e *Executable) Limit {
+	return e.Limit.merge(h.Limit)
+}
+
+func (h *Handler) stdin(r *http.Request, e *Executable) (*Input, error) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, nil
+	}
+
+	if !e.Stdin {
+		return nil, errStdinNotAccepted
+	}
+
+	if e.Body > 0 && r.ContentLength > e.Body {
+		return nil, errInputExceeded
+	}
+
+	return &Input{
+		reader: r.Body,
+		limit:  e.Body,
+	}, nil
+}
+
+func (h *Handler) timeout(e *Executable) time.Duration {
+	if e.Timeout > 0 {
+		return time.Duration(e.Timeout) * time.Second
+	}
+
+	return time.Duration(h.Timeout) * time.Second
+}
+
+func (h *Handler) arguments(q []string, x *Executable) (a []string, e error) {
+	positionals := x.positionals()
+	if len(positionals) > 0 {
+		values, err := h.positional(q, x)
+		if err != nil {
+			return nil, err
+		}
+
+		q = values
+	}
+
+	values := 0
+	for _, v := range q {
+		if strings.HasPrefix(v, "v_") {
+			values++
+		}
+	}
+
+	counts := []int{}
+	if len(positionals) > 0 {
+		assigned, err := assign(positionals, values)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = assigned
+	}
+
+	index, used := 0, 0
+
+	for _, v := range q {
+		if len(v) < 3 && v != "s_" && v != "b_" && v != "v_" {
+			return nil, errArgumentsInvalid
+		}
+
+		switch v[:2] {
+		case "d_":
+			directory, info, err := h.target(v[2:], x)
+			if err != nil {
+				return nil, err
+			}
+
+			if !info.IsDir() {
+				return nil, errTargetNotDirectory
+			}
+
+			a = append(a, h.path(directory, x))
+		case "f_":
+			file, info, err := h.target(v[2:], x)
+			if err != nil {
+				return nil, err
+			}
+
+			if info.IsDir() {
+				return nil, errTargetNotFile
+			}
+
+			a = append(a, h.path(file, x))
+		case "o_":
+			if slices.Contains(x.Options, v[2:]) {
 				a = append(a, v[2:])
-			default:
-				return nil, errArgumentsInvalid
+				break
+			}
+
+			name, value, ok := strings.Cut(v[2:], " ")
+			if x.option(name) == nil {
+				name, value, ok = strings.Cut(v[2:], "=")
+			}
+
+			parameter := x.option(name)
+			if parameter == nil {
+				return nil, errOptionNotFound
+			}
+
+			if !ok {
+				return nil, invalid("option %q requires a value", name)
+			}
+
+			value, err := h.value(parameter, value, x)
+			if err != nil {
+				return nil, err
+			}
+
+			a = append(a, name, value)
+		case "v_":
+			if len(positionals) < 1 {
+				return nil, invalid("executable takes no parameters")
 			}
+
+			for used >= counts[index] {
+				index, used = index+1, 0
+			}
+
+			value, err := h.value(positionals[index], v[2:], x)
+			if err != nil {
+				return nil, err
+			}
+
+			used++
+			a = append(a, value)
+		case "g_":
+			matches, err := h.glob(v[2:], x)
+			if err != nil {
+				return nil, err
+			}
+
+			a = append(a, matches...)
+		case "t_", "s_", "b_":
+			value, err := h.text(v, x)
+			if err != nil {
+				return nil, err
+			}
+
+			if x.Shell {
+				value = quote(value)
+			}
+
+			a = append(a, value)
+		default:
+			return nil, errArgumentsInvalid
 		}
 	}
 
 	return a, nil
 }
 
-func (h *Handler) program(q map[string][]string) (string, []string, error) {
-	h.mutex.Lock()
-	defer h.mutex.Unlock()
+// positional turns g_, s_, and b_ arguments into v_ values, so that glob
+// matches and decoded text fill positional parameters and are counted when
+// they are assigned.
+func (h *Handler) positional(q []string, x *Executable) ([]string, error) {
+	values := []string{}
+	for _, v := range q {
+		switch {
+		case strings.HasPrefix(v, "d_"), strings.HasPrefix(v, "f_"), strings.HasPrefix(v, "t_"):
+			return nil, invalid("argument %q must be given as a parameter with v_", v)
+		case strings.HasPrefix(v, "g_"):
+			matches, err := h.walk(v[2:], x)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, m := range matches {
+				values = append(values, "v_"+m[0])
+			}
+		case strings.HasPrefix(v, "s_"), strings.HasPrefix(v, "b_"):
+			value, err := h.text(v, x)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, "v_"+value)
+		default:
+			values = append(values, v)
+		}
+	}
+
+	return values, nil
+}
+
+// text decodes a t_, s_, or b_ argument. Text that names a path is refused,
+// since a path is given with f_, d_, or v_ so that the path policy applies.
+func (h *Handler) text(v string, x *Executable) (string, error) {
+	value := v[2:]
+	if v[0] == 't' {
+		if len(value) < 2 || !strings.HasPrefix(value, "'") || !strings.HasSuffix(value, "'") {
+			return "", errTextInvalid
+		}
+
+		value = value[1 : len(value)-1]
+	}
+
+	value, err := x.Text.value(value, v[0] == 'b')
+	if err != nil || value == "" {
+		return value, err
+	}
+
+	_, err = os.Lstat(filepath.Join(h.Directory, value))
+	if !filepath.IsLocal(value) || err == nil {
+		return "", text("text %q names a path, which can not be given as text", value)
+	}
+
+	return value, nil
+}
+
+func (h *Handler) path(p string, x *Executable) string {
+	if !x.Namespace.Enabled {
+		return p
+	}
+
+	relative, err := filepath.Rel(h.Directory, p)
+	if err != nil {
+		return p
+	}
+
+	return filepath.Join(namespaceDirectory, relative)
+}
+
+func (h *Handler) mode(q map[string][]string, r http.Header) (string, error) {
+	if len(q["m"]) > 1 {
+		return "", errModeInvalid
+	}
+
+	if len(q["m"]) < 1 {
+		for _, v := range strings.Split(r.Get("Accept"), ",") {
+			media, _, err := mime.ParseMediaType(v)
+			if err == nil && media == "application/json" {
+				return modeJSON, nil
+			}
+
+			if err == nil && media == "text/event-stream" {
+				return modeEvent, nil
+			}
+		}
+
+		return modeBuffer, nil
+	}
+
+	switch q["m"][0] {
+	case modeBuffer, modeStream, modeJSON, modeEvent:
+		return q["m"][0], nil
+	default:
+		return "", errModeInvalid
+	}
+}
+
+// credential returns the credential resolved at startup, and resolves it when
+// the handler is served without being checked.
+func (h *Handler) credential(x *Executable) (*syscall.Credential, error) {
+	key := x
+	if x.Credential.empty() {
+		key = nil
+	}
+
+	credential, ok := h.credentials[key]
+	if ok {
+		return credential, nil
+	}
+
+	return x.Credential.merge(h.Credential).resolve()
+}
+
+func (h *Handler) program(q []string) (string, *Executable, *Action, error) {
+	if len(q) != 1 {
+		return "", nil, nil, errOneExecutableAllowed
+	}
+
+	action, ok := h.Actions[q[0]]
+	if ok && action.Executable == nil {
+		return q[0], &Executable{}, action, nil
+	}
 
-	if len(q["e"]) != 1 {
-		return "", nil, errOneExecutableAllowed
+	if ok {
+		return q[0], action.Executable, action, nil
 	}
 
-	options, ok := h.Executables[q["e"][0]]
+	executable, ok := h.Executables[q[0]]
 	if !ok {
-		return "", nil, errExecutableNotFound
+		return "", nil, nil, errExecutableNotFound
 	}
 
-	return q["e"][0], options, nil
+	return q[0], executable, nil, nil
 }
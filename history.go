@@ -0,0 +1,354 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	historySuccess   string = "success"
+	historyExited    string = "exited"
+	historyTimeout   string = "timeout"
+	historyCancelled string = "cancelled"
+	historyViolated  string = "violated"
+	historyError     string = "error"
+)
+
+type History struct {
+	File      string
+	Age       int
+	Size      int64
+	Output    bool
+	compacted time.Time
+	mutex     sync.Mutex
+}
+
+type Entry struct {
+	Time        time.Time  `json:"time"`
+	Client      string     `json:"client"`
+	Subject     string     `json:"subject"`
+	Job         string     `json:"job,omitempty"`
+	Executables []string   `json:"executables"`
+	Arguments   [][]string `json:"arguments"`
+	Status      string     `json:"status"`
+	ExitCodes   []int      `json:"exit_codes"`
+	Duration    float64    `json:"duration"`
+	Size        int64      `json:"size"`
+	Stdout      string     `json:"stdout,omitempty"`
+	Stderr      string     `json:"stderr,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+func (h *History) record(e *Entry) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	info, err := file.Stat()
+	file.Close()
+
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case h.Size > 0 && info.Size() > h.Size:
+		return h.compact(h.Size / 2)
+	case h.Age > 0 && time.Since(h.compacted) > time.Minute:
+		return h.compact(h.Size)
+	}
+
+	return nil
+}
+
+// compact drops entries older than Age, and then the oldest entries until the
+// file fits in s bytes. Compacting for size leaves the file at half of Size,
+// so that the next records do not compact it again at once.
+func (h *History) compact(s int64) error {
+	entries, err := h.read()
+	if err != nil {
+		return err
+	}
+
+	if h.Age > 0 {
+		cutoff := time.Now().Add(-time.Duration(h.Age) * time.Second)
+		entries = slices.DeleteFunc(entries, func(e *Entry) bool {
+			return e.Time.Before(cutoff)
+		})
+	}
+
+	lines := [][]byte{}
+	size := int64(0)
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		line, err := json.Marshal(entries[i])
+		if err != nil {
+			return err
+		}
+
+		if s > 0 && size+int64(len(line))+1 > s {
+			break
+		}
+
+		lines = append(lines, line)
+		size += int64(len(line)) + 1
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(h.File), filepath.Base(h.File)+".*")
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	for i := len(lines) - 1; i >= 0; i-- {
+		writer.Write(lines[i])
+		writer.WriteByte('\n')
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	h.compacted = time.Now()
+	return os.Rename(file.Name(), h.File)
+}
+
+func (h *History) read() ([]*Entry, error) {
+	file, err := os.Open(h.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*Entry{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	entries := []*Entry{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		entry := &Entry{}
+
+		err := json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// search returns the entries that the client o recorded, newest first.
+func (h *History) search(q map[string][]string, o string) ([]*Entry, error) {
+	h.mutex.Lock()
+	entries, err := h.read()
+	h.mutex.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := time.Time{}, time.Now()
+	limit := 100
+
+	if len(q["from"]) > 0 {
+		from, err = time.Parse(time.RFC3339, q["from"][0])
+		if err != nil {
+			return nil, errHistoryQueryInvalid
+		}
+	}
+
+	if len(q["to"]) > 0 {
+		to, err = time.Parse(time.RFC3339, q["to"][0])
+		if err != nil {
+			return nil, errHistoryQueryInvalid
+		}
+	}
+
+	if len(q["limit"]) > 0 {
+		limit, err = strconv.Atoi(q["limit"][0])
+		if err != nil || limit < 1 {
+			return nil, errHistoryQueryInvalid
+		}
+	}
+
+	matches := []*Entry{}
+	for i := len(entries) - 1; i >= 0 && len(matches) < limit; i-- {
+		entry := entries[i]
+
+		if entry.Client != o {
+			continue
+		}
+
+		if entry.Time.Before(from) || entry.Time.After(to) {
+			continue
+		}
+
+		if len(q["executable"]) > 0 && !slices.ContainsFunc(q["executable"], func(v string) bool {
+			return slices.Contains(entry.Executables, v)
+		}) {
+			continue
+		}
+
+		if len(q["client"]) > 0 && !slices.Contains(q["client"], entry.Client) && !slices.Contains(q["client"], entry.Subject) {
+			continue
+		}
+
+		if len(q["status"]) > 0 && !slices.Contains(q["status"], entry.Status) {
+			continue
+		}
+
+		matches = append(matches, entry)
+	}
+
+	return matches, nil
+}
+
+func (h *Handler) history(response *Response, r *http.Request) {
+	if h.History == nil {
+		response.error(errAccessDenied)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		response.error(errMethodNotAllowed)
+		return
+	}
+
+	entries, err := h.History.search(r.URL.Query(), identity(r))
+	if errors.Is(err, errHistoryQueryInvalid) {
+		response.error(err)
+		return
+	}
+
+	if err != nil {
+		response.error(errHistoryUnreadable)
+		return
+	}
+
+	response.json(http.StatusOK, entries)
+}
+
+func (h *Handler) record(c string, s string, j string, p *Pipeline, codes []int, err error) {
+	if h.History == nil {
+		return
+	}
+
+	entry := &Entry{
+		Time:        time.Now(),
+		Client:      c,
+		Subject:     s,
+		Job:         j,
+		Executables: []string{},
+		Arguments:   [][]string{},
+		Status:      historySuccess,
+		ExitCodes:   codes,
+		Size:        p.stdout.size,
+	}
+
+	start, end := time.Time{}, time.Time{}
+	for _, v := range p.commands {
+		if v.action != "" {
+			entry.Executables = append(entry.Executables, v.action)
+			entry.Arguments = append(entry.Arguments, nil)
+		} else {
+			entry.Executables = append(entry.Executables, v.name)
+			entry.Arguments = append(entry.Arguments, v.argv)
+		}
+
+		if !v.started.IsZero() && (start.IsZero() || v.started.Before(start)) {
+			start = v.started
+		}
+
+		if v.ended.After(end) {
+			end = v.ended
+		}
+	}
+
+	if !start.IsZero() {
+		entry.Duration = end.Sub(start).Seconds()
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, errCommandExited):
+		entry.Status = historyExited
+	case errors.Is(err, errCommandTimeout):
+		entry.Status = historyTimeout
+	case errors.Is(err, errJobCancelled):
+		entry.Status = historyCancelled
+	case errors.Is(err, errCommandViolated):
+		entry.Status = historyViolated
+	default:
+		entry.Status = historyError
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if h.History.Output {
+		entry.Stdout = p.stdout.String()
+
+		for _, v := range p.commands {
+			entry.Stderr += v.stderr.String()
+		}
+	}
+
+	err = h.History.record(entry)
+	if err != nil {
+		h.Log.Error(err.Error(), "file", h.History.File)
+	}
+}
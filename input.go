@@ -0,0 +1,51 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"io"
+	"sync"
+)
+
+type Input struct {
+	reader   io.Reader
+	limit    int64
+	size     int64
+	exceeded bool
+	mutex    sync.Mutex
+}
+
+func (i *Input) Read(b []byte) (int, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.exceeded {
+		return 0, errInputExceeded
+	}
+
+	if i.limit > 0 && int64(len(b)) > i.limit-i.size+1 {
+		b = b[:i.limit-i.size+1]
+	}
+
+	n, err := i.reader.Read(b)
+	i.size += int64(n)
+
+	if i.limit > 0 && i.size > i.limit {
+		i.exceeded = true
+		return 0, errInputExceeded
+	}
+
+	return n, err
+}
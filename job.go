@@ -0,0 +1,465 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const jobsLimit int = 16
+
+const (
+	stateQueued    string = "queued"
+	stateRunning   string = "running"
+	stateFinished  string = "finished"
+	stateCancelled string = "cancelled"
+)
+
+// Jobs keeps submitted jobs. Limit is the largest number of queued and
+// running jobs of one client, and defaults to 16.
+type Jobs struct {
+	Directory string
+	Timeout   int
+	Retention int
+	Limit     int
+	jobs      map[string]*Job
+	active    map[string]int
+	mutex     sync.Mutex
+}
+
+func (j *Jobs) reserve(o string) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	limit := j.Limit
+	if limit < 1 {
+		limit = jobsLimit
+	}
+
+	if j.active == nil {
+		j.active = map[string]int{}
+	}
+
+	if j.active[o] >= limit {
+		return errJobLimit
+	}
+
+	j.active[o]++
+	return nil
+}
+
+func (j *Jobs) free(o string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.active[o]--
+	if j.active[o] < 1 {
+		delete(j.active, o)
+	}
+}
+
+func (j *Jobs) add(b *Job) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.jobs == nil {
+		j.jobs = map[string]*Job{}
+	}
+
+	j.jobs[b.id] = b
+}
+
+func (j *Jobs) get(i string, o string) (*Job, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	job, ok := j.jobs[i]
+	if !ok || job.owner != o {
+		return nil, errJobNotFound
+	}
+
+	return job, nil
+}
+
+func (j *Jobs) list(o string) []*Job {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	jobs := []*Job{}
+	for _, v := range j.jobs {
+		if v.owner == o {
+			jobs = append(jobs, v)
+		}
+	}
+
+	slices.SortFunc(jobs, func(a *Job, b *Job) int {
+		return a.created.Compare(b.created)
+	})
+
+	return jobs
+}
+
+func (j *Jobs) expire(b *Job) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	delete(j.jobs, b.id)
+	b.close()
+}
+
+type Job struct {
+	id       string
+	owner    string
+	subject  string
+	pipeline *Pipeline
+	input    *os.File
+	stdout   *Spool
+	stderr   *Spool
+	events   *Spool
+	log      *Events
+	state    string
+	created  time.Time
+	codes    []int
+	err      error
+	context  context.Context
+	cancel   context.CancelFunc
+	mutex    sync.Mutex
+}
+
+func (j *Job) run(h *Handler) {
+	defer h.Jobs.free(j.owner)
+	defer j.cancel()
+
+	defer func() {
+		if h.Jobs.Retention > 0 {
+			time.AfterFunc(time.Duration(h.Jobs.Retention)*time.Second, func() {
+				h.Jobs.expire(j)
+			})
+		}
+	}()
+
+	defer j.events.finish()
+	defer j.stderr.finish()
+	defer j.stdout.finish()
+
+	defer func() {
+		j.log.exit(j.pipeline, j.codes, j.err)
+	}()
+
+	defer func() {
+		h.record(j.owner, j.subject, j.id, j.pipeline, j.codes, j.err)
+	}()
+
+	err := h.Pool.wait(j.context)
+	if err != nil {
+		j.end(nil, err)
+		return
+	}
+
+	defer h.Pool.release()
+
+	j.mutex.Lock()
+	if j.state == stateCancelled {
+		j.mutex.Unlock()
+		return
+	}
+
+	j.state = stateRunning
+	j.mutex.Unlock()
+
+	j.pipeline.run(j.context)
+	j.end(j.pipeline.result())
+}
+
+func (j *Job) end(c []int, e error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.codes = c
+	j.err = e
+
+	if j.state == stateCancelled {
+		j.err = errJobCancelled
+		return
+	}
+
+	j.state = stateFinished
+}
+
+func (j *Job) stop() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.state == stateQueued || j.state == stateRunning {
+		j.state = stateCancelled
+	}
+
+	j.cancel()
+}
+
+func (j *Job) status() map[string]any {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	status := map[string]any{
+		"id":      j.id,
+		"state":   j.state,
+		"created": j.created,
+	}
+
+	if j.state == stateFinished || j.state == stateCancelled {
+		if j.codes != nil {
+			status["report"] = report(j.pipeline, j.codes, j.err)
+		}
+
+		if j.err != nil {
+			status["error"] = j.err.Error()
+		}
+	}
+
+	return status
+}
+
+func (j *Job) close() {
+	j.cancel()
+	j.stdout.close()
+	j.stderr.close()
+	j.events.close()
+	j.pipeline.close()
+
+	if j.input != nil {
+		j.input.Close()
+		os.Remove(j.input.Name())
+	}
+}
+
+func (h *Handler) jobs(response *Response, r *http.Request) {
+	if h.Jobs == nil {
+		response.error(errAccessDenied)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	owner := identity(r)
+
+	switch {
+	case len(segments) == 1 && r.Method == http.MethodPost:
+		h.submit(response, r, owner, subject(r))
+	case len(segments) == 1 && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		statuses := []map[string]any{}
+		for _, v := range h.Jobs.list(owner) {
+			statuses = append(statuses, v.status())
+		}
+
+		response.json(http.StatusOK, statuses)
+	case len(segments) == 2 || len(segments) == 3:
+		job, err := h.Jobs.get(segments[1], owner)
+		if err != nil {
+			response.error(err)
+			return
+		}
+
+		h.job(response, r, job, segments[2:])
+	default:
+		response.error(errAccessDenied)
+	}
+}
+
+func (h *Handler) job(response *Response, r *http.Request, j *Job, s []string) {
+	switch {
+	case len(s) == 0 && r.Method == http.MethodDelete:
+		j.stop()
+		response.json(http.StatusAccepted, j.status())
+	case len(s) == 0 && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		response.json(http.StatusOK, j.status())
+	case len(s) == 1 && s[0] == "stdout" && r.Method == http.MethodGet:
+		h.output(response, r, j.stdout, 0, response.stream)
+	case len(s) == 1 && s[0] == "stderr" && r.Method == http.MethodGet:
+		h.output(response, r, j.stderr, 0, response.stream)
+	case len(s) == 1 && s[0] == "events" && r.Method == http.MethodGet:
+		last := 0
+		if r.Header.Get("Last-Event-ID") != "" {
+			number, err := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+			if err != nil || number < 0 {
+				response.error(errEventInvalid)
+				return
+			}
+
+			last = number
+		}
+
+		h.output(response, r, j.events, j.log.offset(last), response.events)
+	case len(s) == 1 && (s[0] == "stdout" || s[0] == "stderr" || s[0] == "events"):
+		response.error(errMethodNotAllowed)
+	case len(s) == 0:
+		response.error(errMethodNotAllowed)
+	default:
+		response.error(errAccessDenied)
+	}
+}
+
+func (h *Handler) output(response *Response, r *http.Request, s *Spool, o int64, f func() (io.Writer, error)) {
+	writer, err := f()
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	err = s.copy(r.Context(), writer, o)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		h.Log.Error(err.Error(), "address", r.RemoteAddr, "protocol", r.Proto, "uri", r.RequestURI)
+	}
+}
+
+func (h *Handler) submit(response *Response, r *http.Request, o string, s string) {
+	commands, err := h.stages(r.URL.Query())
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	stdin, err := h.stdin(r, commands[0].executable)
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	err = h.Jobs.reserve(o)
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	created := false
+	defer func() {
+		if !created {
+			h.Jobs.free(o)
+		}
+	}()
+
+	job := &Job{
+		owner:   o,
+		subject: s,
+		state:   stateQueued,
+		created: time.Now(),
+	}
+
+	job.context, job.cancel = context.WithCancel(context.Background())
+
+	id := make([]byte, 16)
+
+	_, err = rand.Read(id)
+	if err != nil {
+		job.cancel()
+		response.error(errJobNotCreated)
+		return
+	}
+
+	job.id = hex.EncodeToString(id)
+
+	if stdin != nil {
+		job.input, err = os.CreateTemp(h.Jobs.Directory, "httpsh-*")
+		if err != nil {
+			job.cancel()
+			response.error(errJobNotCreated)
+			return
+		}
+
+		_, err = io.Copy(job.input, stdin)
+		if err == nil {
+			_, err = job.input.Seek(0, io.SeekStart)
+		}
+
+		if err != nil {
+			job.cancel()
+			job.input.Close()
+			os.Remove(job.input.Name())
+
+			if stdin.exceeded {
+				err = errInputExceeded
+			}
+
+			response.error(err)
+			return
+		}
+
+		stdin = &Input{
+			reader: job.input,
+		}
+	}
+
+	job.stdout, err = spool(h.Jobs.Directory)
+	if err != nil {
+		job.cancel()
+		response.error(errJobNotCreated)
+		return
+	}
+
+	job.stderr, err = spool(h.Jobs.Directory)
+	if err != nil {
+		job.cancel()
+		job.stdout.close()
+		response.error(errJobNotCreated)
+		return
+	}
+
+	job.events, err = spool(h.Jobs.Directory)
+	if err != nil {
+		job.cancel()
+		job.stdout.close()
+		job.stderr.close()
+		response.error(errJobNotCreated)
+		return
+	}
+
+	job.log = &Events{
+		writer: job.events,
+	}
+
+	if h.Jobs.Timeout > 0 {
+		for _, v := range commands {
+			if v.executable.Timeout < 1 {
+				v.timeout = time.Duration(h.Jobs.Timeout) * time.Second
+			}
+		}
+	}
+
+	job.pipeline = h.pipeline(commands, stdin)
+	job.pipeline.stdout.writer = io.MultiWriter(job.stdout, job.log.stream("stdout"))
+	job.pipeline.stdout.capture = h.History != nil && h.History.Output
+
+	for _, v := range commands {
+		v.stderr.writer = io.MultiWriter(job.stderr, job.log.stream("stderr"))
+		v.stderr.capture = true
+	}
+
+	h.Jobs.add(job)
+	created = true
+	go job.run(h)
+
+	response.writer.Header().Set("Location", "/jobs/"+job.id)
+	response.json(http.StatusAccepted, job.status())
+}
@@ -0,0 +1,155 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	landlockRead   uint64 = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockWrite  uint64 = unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE | unix.LANDLOCK_ACCESS_FS_MAKE_DIR | unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SYM | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK | unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_REFER | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	landlockDevice uint64 = unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	landlockFile   uint64 = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// apply lets the command read the directory, the system paths or the
+// configured paths, and the directory of the executable itself, so that a
+// program installed elsewhere can still be executed.
+func (l Landlock) apply(d string, p string) error {
+	if !l.Enabled {
+		return nil
+	}
+
+	version, err := landlock()
+	if err != nil {
+		return nil
+	}
+
+	handled := landlockRead | landlockWrite | unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK
+	if version < 2 {
+		handled &^= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+
+	if version < 3 {
+		handled &^= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attribute := &unix.LandlockRulesetAttr{
+		Access_fs: handled,
+	}
+
+	ruleset, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(attribute)), unsafe.Sizeof(*attribute), 0)
+	if errno != 0 {
+		return errno
+	}
+
+	defer unix.Close(int(ruleset))
+
+	paths := systemPaths
+	if len(l.Paths) > 0 {
+		paths = l.Paths
+	}
+
+	rules := map[string]uint64{}
+	for _, v := range paths {
+		rules[v] = landlockRead
+	}
+
+	for _, v := range systemDevices {
+		rules[v] = landlockDevice
+	}
+
+	rules[filepath.Dir(p)] = landlockRead
+
+	executable, err := filepath.EvalSymlinks(p)
+	if err == nil {
+		rules[filepath.Dir(executable)] = landlockRead
+	}
+
+	rules[d] = landlockRead
+	if l.Write {
+		rules[d] = landlockRead | landlockWrite
+	}
+
+	for k, v := range rules {
+		err := rule(int(ruleset), k, v&handled)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno = unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+func landlock() (int, error) {
+	version, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return int(version), nil
+}
+
+func rule(r int, p string, a uint64) error {
+	file, err := unix.Open(p, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if errors.Is(err, unix.ENOENT) {
+		return nil
+	}
+
+	if err != nil {
+		return &os.PathError{Op: "open", Path: p, Err: err}
+	}
+
+	defer unix.Close(file)
+
+	stat := &unix.Stat_t{}
+
+	err = unix.Fstat(file, stat)
+	if err != nil {
+		return &os.PathError{Op: "stat", Path: p, Err: err}
+	}
+
+	if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+		a &= landlockFile
+	}
+
+	attribute := &unix.LandlockPathBeneathAttr{
+		Allowed_access: a,
+		Parent_fd:      int32(file),
+	}
+
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(r), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(attribute)), 0, 0, 0)
+	if errno != 0 {
+		return &os.PathError{Op: "landlock", Path: p, Err: errno}
+	}
+
+	return nil
+}
@@ -17,17 +17,62 @@ package httpsh
 import "errors"
 
 var (
-	errChangeDirectory      error = errors.New("can not change directory")
-	errMethodNotAllowed     error = errors.New("method is not allowed")
-	errAccessDenied         error = errors.New("access is denied")
-	errQueryInvalid         error = errors.New("query is invalid")
-	errOneExecutableAllowed error = errors.New("one executable allowed")
-	errExecutableNotFound   error = errors.New("executable is not found")
-	errArgumentsInvalid     error = errors.New("arguments are invalid")
-	errTargetNotFound       error = errors.New("target is not found")
-	errTargetNotDirectory   error = errors.New("target is not a directory")
-	errTargetNotFile        error = errors.New("target is not a file")
-	errOptionNotFound       error = errors.New("option is not found")
-	errTextInvalid          error = errors.New("text is invalid")
-	errUnknown              error = errors.New("unknown error")
+	errChangeDirectory       error = errors.New("can not change directory")
+	errMethodNotAllowed      error = errors.New("method is not allowed")
+	errAccessDenied          error = errors.New("access is denied")
+	errQueryInvalid          error = errors.New("query is invalid")
+	errOneExecutableAllowed  error = errors.New("one executable allowed")
+	errExecutableNotFound    error = errors.New("executable is not found")
+	errArgumentsInvalid      error = errors.New("arguments are invalid")
+	errTargetNotFound        error = errors.New("target is not found")
+	errTargetNotDirectory    error = errors.New("target is not a directory")
+	errTargetNotFile         error = errors.New("target is not a file")
+	errOptionNotFound        error = errors.New("option is not found")
+	errTextInvalid           error = errors.New("text is invalid")
+	errCommandTimeout        error = errors.New("command is timed out")
+	errPoolFull              error = errors.New("pool is full")
+	errPoolTimeout           error = errors.New("pool is timed out")
+	errModeInvalid           error = errors.New("mode is invalid")
+	errStreamUnsupported     error = errors.New("stream is not supported")
+	errOutputExceeded        error = errors.New("output is exceeded")
+	errOutputUnreadable      error = errors.New("output is unreadable")
+	errCommandFailed         error = errors.New("command is failed")
+	errCommandExited         error = errors.New("command is exited with non-zero status")
+	errCommandCrashed        error = errors.New("command is crashed")
+	errStdinNotAccepted      error = errors.New("stdin is not accepted")
+	errInputExceeded         error = errors.New("input is exceeded")
+	errCommandNotStarted     error = errors.New("command is not started")
+	errPipelineInvalid       error = errors.New("pipeline is invalid")
+	errJobNotFound           error = errors.New("job is not found")
+	errJobNotCreated         error = errors.New("job is not created")
+	errJobCancelled          error = errors.New("job is cancelled")
+	errJobLimit              error = errors.New("job limit is reached")
+	errHistoryQueryInvalid   error = errors.New("history query is invalid")
+	errHistoryUnreadable     error = errors.New("history is unreadable")
+	errWebSocketInvalid      error = errors.New("websocket is invalid")
+	errWebSocketUnsupported  error = errors.New("websocket is not supported")
+	errOriginDenied          error = errors.New("origin is denied")
+	errTerminalNotAllowed    error = errors.New("terminal is not allowed")
+	errTerminalUnavailable   error = errors.New("terminal is unavailable")
+	errEventInvalid          error = errors.New("last event id is invalid")
+	errCgroupUnavailable     error = errors.New("cgroup is unavailable")
+	errCredentialInvalid     error = errors.New("credential is invalid")
+	errCredentialUnavailable error = errors.New("credential can not be applied")
+	errSeccompInvalid        error = errors.New("seccomp profile is invalid")
+	errSeccompUnsupported    error = errors.New("seccomp is not supported")
+	errNamespaceUnsupported  error = errors.New("namespace is not supported")
+	errResourcesUnsupported  error = errors.New("resources are not supported")
+	errCommandViolated       error = errors.New("command is violated seccomp profile")
+	errPathEscaped           error = errors.New("path is escaped from directory")
+	errPathDenied            error = errors.New("path is denied")
+	errPathHidden            error = errors.New("path is hidden")
+	errPathNotAllowed        error = errors.New("path is not allowed for executable")
+	errSymlinkDenied         error = errors.New("symlink is denied")
+	errPolicyInvalid         error = errors.New("policy is invalid")
+	errSchemaInvalid         error = errors.New("schema is invalid")
+	errTemplateInvalid       error = errors.New("template is invalid")
+	errTextClassInvalid      error = errors.New("text class is invalid")
+	errLimitInvalid          error = errors.New("limit is invalid")
+	errExitInvalid           error = errors.New("exit is invalid")
+	errUnknown               error = errors.New("unknown error")
 )
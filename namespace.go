@@ -0,0 +1,25 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+const (
+	namespaceRoot      string = "/tmp"
+	namespaceDirectory string = "/mnt"
+)
+
+type Namespace struct {
+	Enabled  bool `json:"enabled"`
+	ReadOnly bool `json:"read_only,omitempty"`
+}
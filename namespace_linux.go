@@ -0,0 +1,328 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	namespaceFlags  uintptr = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	namespaceNoRoot uintptr = 1<<0 | 1<<1
+)
+
+func (n Namespace) check() error {
+	return nil
+}
+
+// attributes maps root in the new user namespace to the user that the command
+// would have run as, so files beneath the directory keep their owners.
+func (n Namespace) attributes(a *syscall.SysProcAttr) {
+	if !n.Enabled {
+		return
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	if a.Credential != nil {
+		uid, gid = int(a.Credential.Uid), int(a.Credential.Gid)
+	}
+
+	a.Credential = &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true}
+	a.Cloneflags = namespaceFlags
+	a.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+	a.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: gid, Size: 1}}
+	a.GidMappingsEnableSetgroups = false
+}
+
+// apply builds a root on a tmpfs with the system paths, the devices, the
+// directory, a private /tmp, and /proc, pivots into it, and drops every
+// capability, so the command can not remount the directory writable or bring
+// up a network.
+func (n Namespace) apply(d string) error {
+	if !n.Enabled {
+		return nil
+	}
+
+	directory, err := os.OpenFile(d, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return err
+	}
+
+	defer directory.Close()
+
+	err = unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, "")
+	if err != nil {
+		return &os.PathError{Op: "mount", Path: "/", Err: err}
+	}
+
+	err = unix.Mount("tmpfs", namespaceRoot, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755")
+	if err != nil {
+		return &os.PathError{Op: "mount", Path: namespaceRoot, Err: err}
+	}
+
+	for _, v := range systemPaths {
+		err := bind(v, filepath.Join(namespaceRoot, v), true)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range systemDevices {
+		err := bind(v, filepath.Join(namespaceRoot, v), false)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(filepath.Join(namespaceRoot, namespaceDirectory), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = mount("/proc/self/fd/"+strconv.Itoa(int(directory.Fd())), filepath.Join(namespaceRoot, namespaceDirectory), n.ReadOnly)
+	if err != nil {
+		return err
+	}
+
+	err = os.Mkdir(filepath.Join(namespaceRoot, "tmp"), 0777|os.ModeSticky)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(filepath.Join(namespaceRoot, "tmp"), 0777|os.ModeSticky)
+	if err != nil {
+		return err
+	}
+
+	err = os.Mkdir(filepath.Join(namespaceRoot, "proc"), 0555)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Mount("proc", filepath.Join(namespaceRoot, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+	if err != nil {
+		return &os.PathError{Op: "mount", Path: "/proc", Err: err}
+	}
+
+	err = unix.Chdir(namespaceRoot)
+	if err != nil {
+		return err
+	}
+
+	err = unix.PivotRoot(".", ".")
+	if err != nil {
+		return &os.PathError{Op: "pivot_root", Path: namespaceRoot, Err: err}
+	}
+
+	err = unix.Unmount(".", unix.MNT_DETACH)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Chdir(namespaceDirectory)
+	if err != nil {
+		return err
+	}
+
+	return drop()
+}
+
+// drop keeps root in the namespace from regaining capabilities on exec, and
+// clears them from the bounding, ambient, and thread sets.
+func drop() error {
+	err := unix.Prctl(unix.PR_SET_SECUREBITS, namespaceNoRoot, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	for i := uintptr(0); ; i++ {
+		err := unix.Prctl(unix.PR_CAPBSET_DROP, i, 0, 0, 0)
+		if errors.Is(err, unix.EINVAL) {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err = unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	data := [2]unix.CapUserData{}
+	return unix.Capset(&unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}, &data[0])
+}
+
+// supervise runs the rest of the sandbox in a child, since the process that
+// enters the namespace is its init and ignores signals it does not handle. It
+// forwards signals to the child, reaps orphans, and exits with the child. A
+// child killed by a signal is reported on the status descriptor.
+func supervise(s *Sandbox) error {
+	var status *os.File
+	if s.Status > 0 {
+		status = os.NewFile(uintptr(s.Status), "status")
+		s.Status = 3
+	}
+
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	command := exec.Command(sandboxPath)
+	command.Args = []string{s.Arguments[0]}
+	command.Env = append(os.Environ(), sandboxVariable+"="+string(value))
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if status != nil {
+		command.ExtraFiles = []*os.File{status}
+	}
+
+	_, err = unix.IoctlGetTermios(0, unix.TCGETS)
+	if err == nil {
+		command.SysProcAttr.Foreground = true
+		command.SysProcAttr.Ctty = 0
+	}
+
+	forwarded := make(chan os.Signal, len(signals))
+	for _, v := range signals {
+		if v != syscall.SIGKILL && v != syscall.SIGSTOP {
+			signal.Notify(forwarded, v)
+		}
+	}
+
+	err = command.Start()
+	if err != nil {
+		return err
+	}
+
+	child := command.Process.Pid
+
+	go func() {
+		for v := range forwarded {
+			syscall.Kill(-child, v.(syscall.Signal))
+		}
+	}()
+
+	for {
+		wait := unix.WaitStatus(0)
+
+		pid, err := unix.Wait4(-1, &wait, 0, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if pid != child {
+			continue
+		}
+
+		if wait.Signaled() && status != nil {
+			fmt.Fprintln(status, sandboxSignal+strconv.Itoa(int(wait.Signal())))
+		}
+
+		if wait.Signaled() {
+			os.Exit(128 + int(wait.Signal()))
+		}
+
+		os.Exit(wait.ExitStatus())
+	}
+}
+
+func bind(s string, t string, r bool) error {
+	info, err := os.Lstat(s)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(t), 0755)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(s)
+		if err != nil {
+			return err
+		}
+
+		return os.Symlink(link, t)
+	case info.IsDir():
+		err = os.Mkdir(t, 0755)
+	default:
+		err = os.WriteFile(t, nil, 0644)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return mount(s, t, r)
+}
+
+func mount(s string, t string, r bool) error {
+	err := unix.Mount(s, t, "", unix.MS_BIND|unix.MS_REC, "")
+	if err != nil {
+		return &os.PathError{Op: "mount", Path: s, Err: err}
+	}
+
+	if !r {
+		return nil
+	}
+
+	stat := &unix.Statfs_t{}
+
+	err = unix.Statfs(t, stat)
+	if err != nil {
+		return err
+	}
+
+	flags := uintptr(unix.MS_REMOUNT | unix.MS_BIND | unix.MS_RDONLY)
+	for k, v := range map[int64]uintptr{unix.ST_NOSUID: unix.MS_NOSUID, unix.ST_NODEV: unix.MS_NODEV, unix.ST_NOEXEC: unix.MS_NOEXEC, unix.ST_NOATIME: unix.MS_NOATIME, unix.ST_NODIRATIME: unix.MS_NODIRATIME, unix.ST_RELATIME: unix.MS_RELATIME} {
+		if int64(stat.Flags)&k != 0 {
+			flags |= v
+		}
+	}
+
+	err = unix.Mount("", t, "", flags, "")
+	if err != nil {
+		return &os.PathError{Op: "remount", Path: s, Err: err}
+	}
+
+	return nil
+}
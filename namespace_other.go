@@ -0,0 +1,37 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package httpsh
+
+import "syscall"
+
+func (n Namespace) check() error {
+	if n.Enabled {
+		return errNamespaceUnsupported
+	}
+
+	return nil
+}
+
+func (n Namespace) attributes(a *syscall.SysProcAttr) {}
+
+func (n Namespace) apply(d string) error {
+	return n.check()
+}
+
+func supervise(s *Sandbox) error {
+	return errNamespaceUnsupported
+}
@@ -0,0 +1,203 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	outputCapture int64 = 1 << 16
+	outputSpill   int64 = 1 << 30
+)
+
+const (
+	actionTruncate string = "truncate"
+	actionFail     string = "fail"
+	actionSpill    string = "spill"
+)
+
+// Limit bounds each stream. Spill is the largest spill file in bytes, beyond
+// which the output is exceeded, and defaults to 1 GiB.
+type Limit struct {
+	Stdout int64
+	Stderr int64
+	Action string
+	Spill  int64
+}
+
+func (l Limit) check() error {
+	if l.Stdout < 0 || l.Stderr < 0 || l.Spill < 0 {
+		return errLimitInvalid
+	}
+
+	if l.Action != "" && l.Action != actionTruncate && l.Action != actionFail && l.Action != actionSpill {
+		return errLimitInvalid
+	}
+
+	return nil
+}
+
+func (l Limit) merge(d Limit) Limit {
+	if l.Stdout < 1 {
+		l.Stdout = d.Stdout
+	}
+
+	if l.Stderr < 1 {
+		l.Stderr = d.Stderr
+	}
+
+	if l.Action == "" {
+		l.Action = d.Action
+	}
+
+	if l.Action == "" {
+		l.Action = actionTruncate
+	}
+
+	if l.Spill < 1 {
+		l.Spill = d.Spill
+	}
+
+	if l.Spill < 1 {
+		l.Spill = outputSpill
+	}
+
+	return l
+}
+
+type Output struct {
+	limit     int64
+	action    string
+	maximum   int64
+	writer    io.Writer
+	capture   bool
+	head      bytes.Buffer
+	file      *os.File
+	size      int64
+	truncated bool
+	exceeded  bool
+	mutex     sync.Mutex
+}
+
+func (o *Output) Write(b []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.exceeded {
+		return 0, errOutputExceeded
+	}
+
+	if o.writer != nil && o.action == actionSpill && o.maximum > 0 && o.size+int64(len(b)) > o.maximum {
+		o.exceeded = true
+		return 0, errOutputExceeded
+	}
+
+	if o.limit < 1 || (o.writer != nil && o.action == actionSpill) {
+		return o.write(b)
+	}
+
+	if o.size+int64(len(b)) <= o.limit {
+		return o.write(b)
+	}
+
+	switch o.action {
+	case actionFail:
+		o.exceeded = true
+		return 0, errOutputExceeded
+	case actionSpill:
+		return o.spill(b)
+	default:
+		n, err := o.write(b[:max(o.limit-o.size, 0)])
+		if err != nil {
+			return n, err
+		}
+
+		o.truncated = true
+		return len(b), nil
+	}
+}
+
+func (o *Output) write(b []byte) (int, error) {
+	o.size += int64(len(b))
+
+	if o.writer != nil {
+		if o.capture {
+			o.head.Write(b[:min(int64(len(b)), max(outputCapture-int64(o.head.Len()), 0))])
+		}
+
+		return o.writer.Write(b)
+	}
+
+	if o.file != nil {
+		return o.file.Write(b)
+	}
+
+	return o.head.Write(b)
+}
+
+func (o *Output) spill(b []byte) (int, error) {
+	if o.maximum > 0 && o.size+int64(len(b)) > o.maximum {
+		o.exceeded = true
+		return 0, errOutputExceeded
+	}
+
+	if o.file == nil {
+		file, err := os.CreateTemp("", "httpsh-*")
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = file.Write(o.head.Bytes())
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return 0, err
+		}
+
+		o.file = file
+	}
+
+	return o.write(b)
+}
+
+func (o *Output) reader() (io.Reader, error) {
+	if o.file == nil {
+		return bytes.NewReader(o.head.Bytes()), nil
+	}
+
+	_, err := o.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.file, nil
+}
+
+func (o *Output) String() string {
+	return o.head.String()
+}
+
+func (o *Output) close() {
+	o.head.Reset()
+
+	if o.file != nil {
+		o.file.Close()
+		os.Remove(o.file.Name())
+	}
+}
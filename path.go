@@ -0,0 +1,199 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	symlinkFollow string = "follow"
+	symlinkWithin string = "within"
+	symlinkDeny   string = "deny"
+)
+
+type Policy struct {
+	Deny     []string
+	Hidden   bool
+	Symlinks string
+}
+
+func (p Policy) check() error {
+	switch p.Symlinks {
+	case "", symlinkFollow, symlinkWithin, symlinkDeny:
+	default:
+		return errPolicyInvalid
+	}
+
+	for _, v := range p.Deny {
+		for _, s := range strings.Split(v, "/") {
+			_, err := path.Match(s, "")
+			if err != nil {
+				return errPolicyInvalid
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) permit(r string) error {
+	if r == "." {
+		return nil
+	}
+
+	segments := strings.Split(filepath.ToSlash(r), "/")
+	if p.Hidden && slices.ContainsFunc(segments, func(v string) bool { return strings.HasPrefix(v, ".") }) {
+		return errPathHidden
+	}
+
+	for _, v := range p.Deny {
+		if match(strings.Split(v, "/"), segments) {
+			return errPathDenied
+		}
+	}
+
+	return nil
+}
+
+// match reports whether the segments of a path match the segments of a
+// pattern, where "**" matches any number of segments.
+func match(p []string, s []string) bool {
+	if len(p) < 1 {
+		return len(s) < 1
+	}
+
+	if p[0] == "**" {
+		for i := 0; i <= len(s); i++ {
+			if match(p[1:], s[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(s) < 1 {
+		return false
+	}
+
+	ok, err := path.Match(p[0], s[0])
+	return err == nil && ok && match(p[1:], s[1:])
+}
+
+// target resolves a file or directory argument and applies the path policy
+// and the subtrees of the executable to both the requested and the real path.
+func (h *Handler) target(v string, x *Executable) (string, os.FileInfo, error) {
+	file, info, err := resolve(h.Directory, v, h.Policy.Symlinks)
+	if err != nil {
+		return "", nil, err
+	}
+
+	relatives := []string{filepath.Clean(v)}
+
+	relative, ok := beneath(h.Directory, file)
+	if ok {
+		relatives = append(relatives, relative)
+	}
+
+	for _, r := range relatives {
+		err := h.Policy.permit(r)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(x.Paths) < 1 {
+		return file, info, nil
+	}
+
+	for _, p := range x.Paths {
+		_, allowed := beneath(filepath.Clean(p), relative)
+		if ok && allowed {
+			return file, info, nil
+		}
+	}
+
+	return "", nil, errPathNotAllowed
+}
+
+// resolve opens p beneath d so that neither ".." nor symlinks can leave it, and
+// returns the real path as seen through d. Symlinks are not followed at all
+// with the deny policy, and are followed anywhere with the follow policy.
+// Systems without openat2 fall back to resolving symlinks and checking the
+// result.
+func resolve(d string, p string, s string) (string, os.FileInfo, error) {
+	if !filepath.IsLocal(p) && p != "." {
+		return "", nil, errPathEscaped
+	}
+
+	root, err := filepath.EvalSymlinks(d)
+	if err != nil {
+		return "", nil, errChangeDirectory
+	}
+
+	file, info, err := open(d, root, p, s)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return fallback(d, root, p, s)
+	}
+
+	return file, info, err
+}
+
+func fallback(d string, r string, p string, s string) (string, os.FileInfo, error) {
+	target, err := filepath.EvalSymlinks(filepath.Join(r, p))
+	if err != nil {
+		return "", nil, errTargetNotFound
+	}
+
+	if s == symlinkDeny && target != filepath.Join(r, p) {
+		return "", nil, errSymlinkDenied
+	}
+
+	relative, ok := beneath(r, target)
+	if !ok && s == symlinkFollow {
+		info, err := os.Stat(target)
+		if err != nil {
+			return "", nil, errTargetNotFound
+		}
+
+		return target, info, nil
+	}
+
+	if !ok {
+		return "", nil, errPathEscaped
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", nil, errTargetNotFound
+	}
+
+	return filepath.Join(d, relative), info, nil
+}
+
+func beneath(r string, p string) (string, bool) {
+	relative, err := filepath.Rel(r, p)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return relative, true
+}
@@ -0,0 +1,84 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// open resolves p with openat2 beneath the real directory r, and reports
+// errors.ErrUnsupported on kernels without it.
+func open(d string, r string, p string, s string) (string, os.FileInfo, error) {
+	directory, err := unix.Open(r, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return "", nil, errChangeDirectory
+	}
+
+	defer unix.Close(directory)
+
+	resolve := uint64(unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS)
+
+	switch s {
+	case symlinkFollow:
+		resolve = unix.RESOLVE_NO_MAGICLINKS
+	case symlinkDeny:
+		resolve |= unix.RESOLVE_NO_SYMLINKS
+	}
+
+	descriptor, err := unix.Openat2(directory, p, &unix.OpenHow{
+		Flags:   unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: resolve,
+	})
+
+	switch {
+	case errors.Is(err, unix.ENOSYS):
+		return "", nil, errors.ErrUnsupported
+	case errors.Is(err, unix.EXDEV):
+		return "", nil, errPathEscaped
+	case errors.Is(err, unix.ELOOP) && s == symlinkDeny:
+		return "", nil, errSymlinkDenied
+	case err != nil:
+		return "", nil, errTargetNotFound
+	}
+
+	file := os.NewFile(uintptr(descriptor), p)
+	defer file.Close()
+
+	target, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(descriptor))
+	if err != nil {
+		return "", nil, errTargetNotFound
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", nil, errTargetNotFound
+	}
+
+	relative, ok := beneath(r, target)
+	if !ok && s == symlinkFollow {
+		return target, info, nil
+	}
+
+	if !ok {
+		return "", nil, errPathEscaped
+	}
+
+	return filepath.Join(d, relative), info, nil
+}
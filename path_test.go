@@ -0,0 +1,199 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func fixture(t *testing.T) (string, string) {
+	t.Helper()
+
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+
+	for _, v := range []string{"logs", "docs", ".git"} {
+		err := os.MkdirAll(filepath.Join(root, v), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.Mkdir(outside, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"root/logs/app.log", "root/docs/readme", "root/secret.pem", "root/.git/config", "root/.env", "outside/file"} {
+		err := os.WriteFile(filepath.Join(base, v), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"inside":  "docs/readme",
+		"escape":  "../outside/file",
+		"logs/up": "../docs",
+	}
+
+	for k, v := range links {
+		err := os.Symlink(v, filepath.Join(root, k))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root, outside
+}
+
+func TestTarget(t *testing.T) {
+	root, outside := fixture(t)
+
+	tests := []struct {
+		name   string
+		value  string
+		policy Policy
+		paths  []string
+		file   string
+		err    error
+	}{
+		{name: "file", value: "logs/app.log", file: "logs/app.log"},
+		{name: "directory", value: "logs", file: "logs"},
+		{name: "root", value: ".", file: "."},
+		{name: "missing", value: "logs/missing.log", err: errTargetNotFound},
+		{name: "parent", value: "../outside/file", err: errPathEscaped},
+		{name: "inner parent", value: "logs/../../outside/file", err: errPathEscaped},
+		{name: "absolute", value: "/etc/passwd", err: errPathEscaped},
+		{name: "clean parent", value: "logs/../docs/readme", file: "docs/readme"},
+		{name: "symlink inside", value: "inside", policy: Policy{Symlinks: symlinkWithin}, file: "docs/readme"},
+		{name: "symlink escape", value: "escape", policy: Policy{Symlinks: symlinkWithin}, err: errPathEscaped},
+		{name: "symlink follow", value: "escape", policy: Policy{Symlinks: symlinkFollow}, file: filepath.Join(outside, "file")},
+		{name: "symlink deny", value: "inside", policy: Policy{Symlinks: symlinkDeny}, err: errSymlinkDenied},
+		{name: "deny", value: "secret.pem", policy: Policy{Deny: []string{"**/*.pem"}}, err: errPathDenied},
+		{name: "deny subtree", value: ".git/config", policy: Policy{Deny: []string{"**/.git/**"}}, err: errPathDenied},
+		{name: "deny other", value: "logs/app.log", policy: Policy{Deny: []string{"**/*.pem"}}, file: "logs/app.log"},
+		{name: "hidden", value: ".env", policy: Policy{Hidden: true}, err: errPathHidden},
+		{name: "hidden directory", value: ".git/config", policy: Policy{Hidden: true}, err: errPathHidden},
+		{name: "hidden off", value: ".env", file: ".env"},
+		{name: "subtree", value: "logs/app.log", paths: []string{"logs"}, file: "logs/app.log"},
+		{name: "subtree other", value: "docs/readme", paths: []string{"logs"}, err: errPathNotAllowed},
+		{name: "subtree parent", value: "logs/../docs/readme", paths: []string{"logs"}, err: errPathNotAllowed},
+		{name: "subtree symlink", value: "logs/up/readme", policy: Policy{Symlinks: symlinkWithin}, paths: []string{"logs"}, err: errPathNotAllowed},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			handler := &Handler{
+				Directory: root + string(filepath.Separator),
+				Policy:    v.policy,
+			}
+
+			file, _, err := handler.target(v.value, &Executable{Paths: v.paths})
+			if !errors.Is(err, v.err) {
+				t.Fatalf("target(%q) error is %v, want %v", v.value, err, v.err)
+			}
+
+			if v.err != nil {
+				return
+			}
+
+			want := v.file
+			if !filepath.IsAbs(want) {
+				want = filepath.Join(root, want)
+			}
+
+			if filepath.Clean(file) != want {
+				t.Fatalf("target(%q) is %q, want %q", v.value, file, want)
+			}
+		})
+	}
+}
+
+func TestFallback(t *testing.T) {
+	root, _ := fixture(t)
+
+	tests := []struct {
+		value    string
+		symlinks string
+		err      error
+	}{
+		{value: "logs/app.log"},
+		{value: "inside", symlinks: symlinkWithin},
+		{value: "escape", symlinks: symlinkWithin, err: errPathEscaped},
+		{value: "escape", symlinks: symlinkFollow},
+		{value: "inside", symlinks: symlinkDeny, err: errSymlinkDenied},
+		{value: "logs/missing.log", err: errTargetNotFound},
+	}
+
+	for _, v := range tests {
+		_, _, err := fallback(root, root, v.value, v.symlinks)
+		if !errors.Is(err, v.err) {
+			t.Errorf("fallback(%q, %q) error is %v, want %v", v.value, v.symlinks, err, v.err)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		err    error
+	}{
+		{policy: Policy{}},
+		{policy: Policy{Deny: []string{"**/*.pem", "**/.git/**"}, Symlinks: symlinkWithin}},
+		{policy: Policy{Symlinks: "sometimes"}, err: errPolicyInvalid},
+		{policy: Policy{Deny: []string{"logs/[a-"}}, err: errPolicyInvalid},
+	}
+
+	for _, v := range tests {
+		err := v.policy.check()
+		if !errors.Is(err, v.err) {
+			t.Errorf("check(%+v) error is %v, want %v", v.policy, err, v.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{pattern: "**/*.pem", path: "key.pem", match: true},
+		{pattern: "**/*.pem", path: "certs/ca/key.pem", match: true},
+		{pattern: "**/*.pem", path: "certs/key.pem.bak", match: false},
+		{pattern: "**/.git/**", path: ".git", match: true},
+		{pattern: "**/.git/**", path: "src/.git/objects/pack", match: true},
+		{pattern: "**/.git/**", path: "src/.github/workflows", match: false},
+		{pattern: "logs/*.log", path: "logs/app.log", match: true},
+		{pattern: "logs/*.log", path: "logs/old/app.log", match: false},
+	}
+
+	for _, v := range tests {
+		ok := match(strings.Split(v.pattern, "/"), strings.Split(v.path, "/"))
+		if ok != v.match {
+			t.Errorf("match(%q, %q) is %v, want %v", v.pattern, v.path, ok, v.match)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+)
+
+type Pipeline struct {
+	commands []*Command
+	stdin    *Input
+	stdout   *Output
+	errors   []error
+}
+
+func (p *Pipeline) run(x context.Context) {
+	x, cancel := context.WithCancel(x)
+	defer cancel()
+
+	p.errors = make([]error, len(p.commands))
+
+	if p.stdin != nil {
+		p.commands[0].stdin = p.stdin
+	}
+
+	p.commands[len(p.commands)-1].stdout = p.stdout
+
+	files := []*os.File{}
+	for i := range p.commands[1:] {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			closes(files)
+
+			for j := range p.errors {
+				p.errors[j] = err
+			}
+
+			return
+		}
+
+		p.commands[i].stdout = writer
+		p.commands[i+1].stdin = reader
+
+		files = append(files, reader, writer)
+	}
+
+	started := 0
+	for i, v := range p.commands {
+		err := v.start(x)
+		if err != nil {
+			p.errors[i] = err
+
+			for j := i + 1; j < len(p.errors); j++ {
+				p.errors[j] = errCommandNotStarted
+			}
+
+			cancel()
+			break
+		}
+
+		started++
+	}
+
+	closes(files)
+
+	for i := range started {
+		p.errors[i] = p.commands[i].wait()
+	}
+}
+
+func (p *Pipeline) result() ([]int, error) {
+	codes := make([]int, len(p.errors))
+	for i, v := range p.errors {
+		codes[i] = status(v)
+	}
+
+	index := p.index()
+
+	if p.stdin != nil && p.stdin.exceeded {
+		return codes, errInputExceeded
+	}
+
+	if p.stdout.exceeded {
+		return codes, errOutputExceeded
+	}
+
+	for _, v := range p.commands {
+		if v.stderr.exceeded {
+			return codes, errOutputExceeded
+		}
+	}
+
+	if index < 0 {
+		return codes, nil
+	}
+
+	return codes, failure(p.errors[index], p.commands[index].stderr.String())
+}
+
+func (p *Pipeline) index() int {
+	index := -1
+	for i, v := range p.errors {
+		if v == nil || errors.Is(v, errCommandNotStarted) || (i < len(p.errors)-1 && signaled(v, syscall.SIGPIPE)) {
+			continue
+		}
+
+		index = i
+	}
+
+	return index
+}
+
+func (p *Pipeline) truncated() []string {
+	streams := []string{}
+	if p.stdout.truncated {
+		streams = append(streams, "stdout")
+	}
+
+	for _, v := range p.commands {
+		if v.stderr.truncated {
+			streams = append(streams, "stderr")
+			break
+		}
+	}
+
+	return streams
+}
+
+func (p *Pipeline) close() {
+	p.stdout.close()
+
+	for _, v := range p.commands {
+		v.stderr.close()
+	}
+}
+
+func closes(f []*os.File) {
+	for _, v := range f {
+		v.Close()
+	}
+}
+
+func (p *Pipeline) usage() []*Usage {
+	usages := []*Usage{}
+	for _, v := range p.commands {
+		usages = append(usages, usage(v.state))
+	}
+
+	return usages
+}
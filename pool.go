@@ -0,0 +1,105 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type Pool struct {
+	Size    int
+	Queue   int
+	Timeout int
+	Retry   int
+	once    sync.Once
+	slots   chan struct{}
+	queue   chan struct{}
+}
+
+func (p *Pool) acquire(c context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.once.Do(p.init)
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	select {
+	case p.queue <- struct{}{}:
+	default:
+		return errPoolFull
+	}
+
+	defer func() {
+		<-p.queue
+	}()
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		c, cancel = context.WithTimeout(c, time.Duration(p.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-c.Done():
+		return errPoolTimeout
+	}
+}
+
+// wait takes a slot for a job, which stays queued until one is free or it is
+// cancelled, without holding a place in the queue of waiting requests.
+func (p *Pool) wait(c context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.once.Do(p.init)
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+func (p *Pool) release() {
+	if p == nil {
+		return
+	}
+
+	<-p.slots
+}
+
+func (p *Pool) init() {
+	size := p.Size
+	if size < 1 {
+		size = runtime.NumCPU()
+	}
+
+	p.slots = make(chan struct{}, size)
+	p.queue = make(chan struct{}, max(p.Queue, 0))
+}
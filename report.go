@@ -0,0 +1,122 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"errors"
+	"time"
+)
+
+type Report struct {
+	Executable string     `json:"executable,omitempty"`
+	Arguments  []string   `json:"arguments,omitempty"`
+	Stdout     string     `json:"stdout"`
+	Stderr     string     `json:"stderr"`
+	ExitCode   int        `json:"exit_code"`
+	Signal     string     `json:"signal,omitempty"`
+	Start      time.Time  `json:"start"`
+	End        time.Time  `json:"end"`
+	Duration   float64    `json:"duration"`
+	Truncated  []string   `json:"truncated"`
+	Resources  *Resources `json:"resources,omitempty"`
+	Cgroup     *Cgroup    `json:"cgroup,omitempty"`
+	Usage      *Usage     `json:"usage,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Stages     []*Report  `json:"stages,omitempty"`
+}
+
+func report(p *Pipeline, s []int, err error) *Report {
+	stages := []*Report{}
+	for i, v := range p.commands {
+		stages = append(stages, stage(v, s[i], p.errors[i]))
+	}
+
+	if len(stages) == 1 {
+		stages[0].Truncated = p.truncated()
+		stages[0].Error = ""
+		if err != nil && !errors.Is(err, errCommandExited) {
+			stages[0].Error = err.Error()
+		}
+
+		return stages[0]
+	}
+
+	report := &Report{
+		Start:     stages[0].Start,
+		End:       stages[0].End,
+		Truncated: p.truncated(),
+		Stages:    stages,
+	}
+
+	for _, v := range stages {
+		if v.End.After(report.End) {
+			report.End = v.End
+		}
+	}
+
+	index := p.index()
+	if index >= 0 {
+		report.ExitCode = s[index]
+	}
+
+	report.Duration = report.End.Sub(report.Start).Seconds()
+
+	if err != nil && !errors.Is(err, errCommandExited) {
+		report.Error = err.Error()
+	}
+
+	return report
+}
+
+func stage(c *Command, s int, err error) *Report {
+	report := &Report{
+		Executable: c.name,
+		Arguments:  c.argv,
+		Stderr:     c.stderr.String(),
+		ExitCode:   s,
+		Start:      c.started,
+		End:        c.ended,
+		Duration:   c.ended.Sub(c.started).Seconds(),
+		Truncated:  []string{},
+		Usage:      usage(c.state),
+	}
+
+	if c.action != "" {
+		report.Executable = c.action
+		report.Arguments = nil
+	}
+
+	if !c.executable.Resources.empty() {
+		resources := c.executable.Resources
+		report.Resources = &resources
+	}
+
+	if c.leaf != "" {
+		cgroup := c.executable.Cgroup
+		report.Cgroup = &cgroup
+	}
+
+	signal, ok := killer(err)
+	if ok {
+		report.Signal = signal.String()
+	}
+
+	err = failure(err, report.Stderr)
+	if err != nil && !errors.Is(err, errCommandExited) {
+		report.Error = err.Error()
+	}
+
+	return report
+}
@@ -0,0 +1,125 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const cgroupPeriod int = 100000
+
+type Resources struct {
+	CPU       uint64 `json:"cpu,omitempty"`
+	Memory    uint64 `json:"memory,omitempty"`
+	File      uint64 `json:"file,omitempty"`
+	Files     uint64 `json:"files,omitempty"`
+	Processes uint64 `json:"processes,omitempty"`
+}
+
+func (r Resources) empty() bool {
+	return r == Resources{}
+}
+
+type Cgroup struct {
+	Memory int64 `json:"memory,omitempty"`
+	CPU    int   `json:"cpu,omitempty"`
+}
+
+func (c Cgroup) empty() bool {
+	return c == Cgroup{}
+}
+
+func (c Cgroup) create(d string) (string, *os.File, error) {
+	leaf, err := os.MkdirTemp(d, "httpsh-")
+	if err != nil {
+		return "", nil, errCgroupUnavailable
+	}
+
+	if c.Memory > 0 {
+		err = os.WriteFile(filepath.Join(leaf, "memory.max"), []byte(strconv.FormatInt(c.Memory, 10)), 0)
+	}
+
+	if err == nil && c.CPU > 0 {
+		err = os.WriteFile(filepath.Join(leaf, "cpu.max"), []byte(strconv.Itoa(c.CPU*cgroupPeriod/100)+" "+strconv.Itoa(cgroupPeriod)), 0)
+	}
+
+	if err != nil {
+		os.Remove(leaf)
+		return "", nil, errCgroupUnavailable
+	}
+
+	file, err := os.Open(leaf)
+	if err != nil {
+		os.Remove(leaf)
+		return "", nil, errCgroupUnavailable
+	}
+
+	return leaf, file, nil
+}
+
+func remove(d string) {
+	if d == "" {
+		return
+	}
+
+	os.WriteFile(filepath.Join(d, "cgroup.kill"), []byte("1"), 0)
+
+	for i := 0; i < 10; i++ {
+		if os.Remove(d) == nil {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type Usage struct {
+	User   float64 `json:"user"`
+	System float64 `json:"system"`
+	Memory int64   `json:"memory"`
+	Read   int64   `json:"read"`
+	Write  int64   `json:"write"`
+}
+
+func usage(s *os.ProcessState) *Usage {
+	if s == nil {
+		return nil
+	}
+
+	rusage, ok := s.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return nil
+	}
+
+	return &Usage{
+		User:   time.Duration(rusage.Utime.Nano()).Seconds(),
+		System: time.Duration(rusage.Stime.Nano()).Seconds(),
+		Memory: int64(rusage.Maxrss) * 1024,
+		Read:   int64(rusage.Inblock) * 512,
+		Write:  int64(rusage.Oublock) * 512,
+	}
+}
+
+func (u *Usage) String() string {
+	if u == nil {
+		return "-"
+	}
+
+	return "user=" + strconv.FormatFloat(u.User, 'f', -1, 64) + "; system=" + strconv.FormatFloat(u.System, 'f', -1, 64) + "; memory=" + strconv.FormatInt(u.Memory, 10)
+}
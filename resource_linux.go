@@ -0,0 +1,55 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func (r Resources) check() error {
+	return nil
+}
+
+func (r Resources) apply() error {
+	limits := map[int]uint64{
+		unix.RLIMIT_CPU:    r.CPU,
+		unix.RLIMIT_AS:     r.Memory,
+		unix.RLIMIT_FSIZE:  r.File,
+		unix.RLIMIT_NOFILE: r.Files,
+		unix.RLIMIT_NPROC:  r.Processes,
+	}
+
+	for k, v := range limits {
+		if v == 0 {
+			continue
+		}
+
+		err := unix.Setrlimit(k, &unix.Rlimit{Cur: v, Max: v})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func attach(a *syscall.SysProcAttr, f *os.File) error {
+	a.UseCgroupFD = true
+	a.CgroupFD = int(f.Fd())
+	return nil
+}
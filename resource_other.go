@@ -0,0 +1,38 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package httpsh
+
+import (
+	"os"
+	"syscall"
+)
+
+func (r Resources) check() error {
+	if !r.empty() {
+		return errResourcesUnsupported
+	}
+
+	return nil
+}
+
+func (r Resources) apply() error {
+	return r.check()
+}
+
+func attach(a *syscall.SysProcAttr, f *os.File) error {
+	return errCgroupUnavailable
+}
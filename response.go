@@ -15,9 +15,26 @@
 package httpsh
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	exitHeader        string = "header"
+	exitUnprocessable string = "unprocessable"
+)
+
+const (
+	headerExitCode  string = "Httpsh-Exit-Code"
+	headerError     string = "Httpsh-Error"
+	headerTruncated string = "Httpsh-Truncated"
+	headerUsage     string = "Httpsh-Usage"
 )
 
 type Response struct {
@@ -25,24 +42,177 @@ type Response struct {
 	request *http.Request
 	mime    string
 	methods []string
+	retry   int
+	mode    string
+	exit    string
 	log     *slog.Logger
 }
 
-func (r *Response) error(c int, e error) (int, error) {
+func (r *Response) error(e error) (int, error) {
 	if e.Error() == "" {
 		e = errUnknown
 	}
 
+	c := r.status(e)
+
 	r.log.Error(e.Error(), "address", r.request.RemoteAddr, "protocol", r.request.Proto, "uri", r.request.RequestURI)
+	if errors.Is(e, errPathEscaped) || errors.Is(e, errPathDenied) || errors.Is(e, errPathHidden) || errors.Is(e, errPathNotAllowed) || errors.Is(e, errSymlinkDenied) {
+		r.log.Warn("audit", "event", e.Error(), "address", r.request.RemoteAddr, "client", identity(r.request), "subject", subject(r.request), "uri", r.request.RequestURI)
+	}
+
+	if r.mode == modeJSON {
+		r.headers(c)
+		return 0, r.json(c, map[string]string{"error": e.Error()})
+	}
+
 	return r.write(c, e.Error())
 }
 
 func (r *Response) write(c int, s string) (int, error) {
+	r.headers(c)
+	r.writer.Header().Set("Content-Type", r.mime)
+	r.writer.WriteHeader(c)
+	return r.writer.Write([]byte(s))
+}
+
+func (r *Response) status(e error) int {
+	switch {
+	case errors.Is(e, errMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(e, errAccessDenied), errors.Is(e, errTerminalNotAllowed), errors.Is(e, errOriginDenied), errors.Is(e, errCommandViolated), errors.Is(e, errPathEscaped), errors.Is(e, errPathDenied), errors.Is(e, errPathHidden), errors.Is(e, errPathNotAllowed), errors.Is(e, errSymlinkDenied):
+		return http.StatusForbidden
+	case errors.Is(e, errJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(e, errInputExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(e, errCommandExited):
+		if r.exit == exitUnprocessable {
+			return http.StatusUnprocessableEntity
+		}
+
+		return http.StatusOK
+	case errors.Is(e, errCommandCrashed):
+		return http.StatusBadGateway
+	case errors.Is(e, errPoolFull), errors.Is(e, errPoolTimeout), errors.Is(e, errJobLimit):
+		return http.StatusServiceUnavailable
+	case errors.Is(e, errCommandTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(e, errOutputExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(e, errChangeDirectory), errors.Is(e, errStreamUnsupported), errors.Is(e, errOutputUnreadable), errors.Is(e, errCommandFailed), errors.Is(e, errJobNotCreated), errors.Is(e, errHistoryUnreadable), errors.Is(e, errWebSocketUnsupported), errors.Is(e, errTerminalUnavailable), errors.Is(e, errCredentialInvalid), errors.Is(e, errCredentialUnavailable), errors.Is(e, errSeccompInvalid), errors.Is(e, errSeccompUnsupported), errors.Is(e, errNamespaceUnsupported), errors.Is(e, errResourcesUnsupported), errors.Is(e, errSchemaInvalid), errors.Is(e, errPolicyInvalid), errors.Is(e, errTemplateInvalid), errors.Is(e, errTextClassInvalid), errors.Is(e, errLimitInvalid), errors.Is(e, errExitInvalid), errors.Is(e, errUnknown):
+		return http.StatusInternalServerError
+	case errors.Is(e, errQueryInvalid), errors.Is(e, errOneExecutableAllowed), errors.Is(e, errExecutableNotFound), errors.Is(e, errArgumentsInvalid), errors.Is(e, errTargetNotFound), errors.Is(e, errTargetNotDirectory), errors.Is(e, errTargetNotFile), errors.Is(e, errOptionNotFound), errors.Is(e, errTextInvalid), errors.Is(e, errModeInvalid), errors.Is(e, errStdinNotAccepted), errors.Is(e, errPipelineInvalid), errors.Is(e, errHistoryQueryInvalid), errors.Is(e, errWebSocketInvalid), errors.Is(e, errEventInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (r *Response) code(c ...int) {
+	codes := []string{}
+	for _, v := range c {
+		codes = append(codes, strconv.Itoa(v))
+	}
+
+	r.writer.Header().Set(headerExitCode, strings.Join(codes, ", "))
+}
+
+func (r *Response) headers(c int) {
 	if c == http.StatusMethodNotAllowed {
 		r.writer.Header().Set("Allow", strings.Join(r.methods, ", "))
 	}
 
+	if c == http.StatusServiceUnavailable && r.retry > 0 {
+		r.writer.Header().Set("Retry-After", strconv.Itoa(r.retry))
+	}
+}
+
+func (r *Response) send(c int, b io.Reader) (int64, error) {
 	r.writer.Header().Set("Content-Type", r.mime)
 	r.writer.WriteHeader(c)
-	return r.writer.Write([]byte(s))
+	return io.Copy(r.writer, b)
+}
+
+func (r *Response) json(c int, v any) error {
+	r.writer.Header().Set("Content-Type", "application/json")
+	r.writer.WriteHeader(c)
+	return json.NewEncoder(r.writer).Encode(v)
+}
+
+func (r *Response) truncate(s []string) {
+	if len(s) < 1 {
+		return
+	}
+
+	r.writer.Header().Set(headerTruncated, strings.Join(s, ", "))
+}
+
+func (r *Response) usage(u []*Usage) {
+	usages := []string{}
+	for _, v := range u {
+		usages = append(usages, v.String())
+	}
+
+	r.writer.Header().Set(headerUsage, strings.Join(usages, ", "))
+}
+
+func (r *Response) stream() (io.Writer, error) {
+	r.writer.Header().Set("Trailer", strings.Join([]string{headerExitCode, headerError, headerTruncated, headerUsage}, ", "))
+	return r.flusher(r.mime)
+}
+
+func (r *Response) events() (io.Writer, error) {
+	r.writer.Header().Set("Cache-Control", "no-cache")
+	return r.flusher("text/event-stream")
+}
+
+func (r *Response) flusher(m string) (io.Writer, error) {
+	flusher, ok := r.writer.(http.Flusher)
+	if !ok {
+		return nil, errStreamUnsupported
+	}
+
+	http.NewResponseController(r.writer).SetWriteDeadline(time.Time{})
+
+	r.writer.Header().Set("Content-Type", m)
+	r.writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &Flusher{
+		writer:  r.writer,
+		flusher: flusher,
+	}, nil
+}
+
+func (r *Response) finish(c []int, e error) {
+	r.code(c...)
+	if e == nil {
+		return
+	}
+
+	if e.Error() == "" {
+		e = errUnknown
+	}
+
+	r.log.Error(e.Error(), "address", r.request.RemoteAddr, "protocol", r.request.Proto, "uri", r.request.RequestURI)
+	r.failed(e)
+}
+
+func (r *Response) failed(e error) {
+	r.writer.Header().Set(headerError, strings.Join(strings.Fields(e.Error()), " "))
+}
+
+type Flusher struct {
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+func (f *Flusher) Write(b []byte) (int, error) {
+	n, err := f.writer.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	f.flusher.Flush()
+	return n, nil
 }
@@ -0,0 +1,140 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+const (
+	sandboxVariable string = "HTTPSH_SANDBOX"
+	sandboxPath     string = "/proc/self/exe"
+	sandboxStatus   int    = 126
+	sandboxSignal   string = "signal "
+)
+
+var (
+	systemPaths   []string = []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/etc"}
+	systemDevices []string = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom", "/dev/tty"}
+)
+
+// Sandbox is handed to a re-executed httpsh process, which applies it to
+// itself and then replaces itself with the command. Settings that Go can not
+// apply between fork and exec are applied here. Landlock and seccomp only
+// confine the calling thread, so the thread is locked until exec. A failure is
+// written to the status descriptor, which is closed on exec, so it can not be
+// mistaken for the command exiting with the same code.
+type Sandbox struct {
+	Path      string    `json:"path"`
+	Arguments []string  `json:"arguments"`
+	Directory string    `json:"directory"`
+	Resources Resources `json:"resources"`
+	Landlock  Landlock  `json:"landlock"`
+	Seccomp   []string  `json:"seccomp"`
+	Namespace Namespace `json:"namespace"`
+	Status    int       `json:"status"`
+}
+
+// Init must be called first in main. It returns immediately unless the
+// process is a sandbox started by a Handler.
+func Init() {
+	value, ok := os.LookupEnv(sandboxVariable)
+	if !ok {
+		return
+	}
+
+	runtime.LockOSThread()
+	os.Unsetenv(sandboxVariable)
+
+	sandbox := &Sandbox{}
+
+	err := json.Unmarshal([]byte(value), sandbox)
+	if err == nil && sandbox.Status > 0 {
+		syscall.CloseOnExec(sandbox.Status)
+	}
+
+	if err == nil {
+		err = sandbox.Namespace.apply(sandbox.Directory)
+	}
+
+	if err == nil && sandbox.Namespace.Enabled {
+		sandbox.Directory = namespaceDirectory
+		sandbox.Namespace = Namespace{}
+		err = supervise(sandbox)
+	}
+
+	if err == nil {
+		err = sandbox.Resources.apply()
+	}
+
+	if err == nil {
+		err = sandbox.Landlock.apply(sandbox.Directory, sandbox.Path)
+	}
+
+	if err == nil && len(sandbox.Seccomp) > 0 {
+		err = execute(sandbox.Seccomp, sandbox.Path, sandbox.Arguments, os.Environ())
+	}
+
+	if err == nil {
+		err = syscall.Exec(sandbox.Path, sandbox.Arguments, os.Environ())
+	}
+
+	output := os.Stderr
+	if sandbox.Status > 0 {
+		output = os.NewFile(uintptr(sandbox.Status), "status")
+	}
+
+	fmt.Fprintln(output, "httpsh: "+err.Error())
+	os.Exit(sandboxStatus)
+}
+
+func (s *Sandbox) empty() bool {
+	return s.Resources.empty() && !s.Landlock.Enabled && len(s.Seccomp) < 1 && !s.Namespace.Enabled
+}
+
+// wrap returns the read end of the status pipe, which the caller closes after
+// reading it once the command has ended.
+func (s *Sandbox) wrap(c *exec.Cmd) (*os.File, error) {
+	if c.Err != nil || s.empty() {
+		return nil, nil
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Path = c.Path
+	s.Arguments = c.Args
+	s.Status = 3 + len(c.ExtraFiles)
+
+	value, err := json.Marshal(s)
+	if err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, err
+	}
+
+	c.Path = sandboxPath
+	c.Args = []string{c.Args[0]}
+	c.Env = append(os.Environ(), sandboxVariable+"="+string(value))
+	c.ExtraFiles = append(c.ExtraFiles, writer)
+	return reader, nil
+}
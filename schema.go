@@ -0,0 +1,222 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	typeInt       string = "int"
+	typeEnum      string = "enum"
+	typeString    string = "string"
+	typeDuration  string = "duration"
+	typeFile      string = "file"
+	typeDirectory string = "directory"
+	typePath      string = "path"
+)
+
+// Parameter describes the value of an option when Option is set, or a
+// positional parameter otherwise. Cardinality applies to positional parameters
+// and is "?", "*", "+", a count, or a "least..most" range, and defaults to one.
+type Parameter struct {
+	Name        string   `json:"name,omitempty"`
+	Option      string   `json:"option,omitempty"`
+	Type        string   `json:"type"`
+	Minimum     *int64   `json:"minimum,omitempty"`
+	Maximum     *int64   `json:"maximum,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Cardinality string   `json:"cardinality,omitempty"`
+}
+
+func (p *Parameter) label() string {
+	if p.Option != "" {
+		return p.Option
+	}
+
+	return p.Name
+}
+
+func (p *Parameter) cardinality() (int, int, error) {
+	switch p.Cardinality {
+	case "":
+		return 1, 1, nil
+	case "?":
+		return 0, 1, nil
+	case "*":
+		return 0, -1, nil
+	case "+":
+		return 1, -1, nil
+	}
+
+	first, last, ok := strings.Cut(p.Cardinality, "..")
+	if !ok {
+		last = first
+	}
+
+	least, err := strconv.Atoi(first)
+	if err != nil || least < 0 {
+		return 0, 0, errSchemaInvalid
+	}
+
+	most := -1
+	if last != "" {
+		most, err = strconv.Atoi(last)
+		if err != nil || most < least {
+			return 0, 0, errSchemaInvalid
+		}
+	}
+
+	return least, most, nil
+}
+
+func (p *Parameter) check() error {
+	if p.label() == "" {
+		return errSchemaInvalid
+	}
+
+	switch p.Type {
+	case typeInt, typeString, typeDuration, typeFile, typeDirectory, typePath:
+	case typeEnum:
+		if len(p.Values) < 1 {
+			return errSchemaInvalid
+		}
+	default:
+		return errSchemaInvalid
+	}
+
+	_, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return errSchemaInvalid
+	}
+
+	_, _, err = p.cardinality()
+	return err
+}
+
+func (h *Handler) value(p *Parameter, v string, x *Executable) (string, error) {
+	if p.Pattern != "" {
+		matched, err := regexp.MatchString("^(?:"+p.Pattern+")$", v)
+		if err != nil || !matched {
+			return "", invalid("value %q of %q does not match %s", v, p.label(), p.Pattern)
+		}
+	}
+
+	switch p.Type {
+	case typeInt:
+		number, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return "", invalid("value %q of %q is not an integer", v, p.label())
+		}
+
+		if p.Minimum != nil && number < *p.Minimum {
+			return "", invalid("value %q of %q is less than %d", v, p.label(), *p.Minimum)
+		}
+
+		if p.Maximum != nil && number > *p.Maximum {
+			return "", invalid("value %q of %q is greater than %d", v, p.label(), *p.Maximum)
+		}
+	case typeEnum:
+		if !slices.Contains(p.Values, v) {
+			return "", invalid("value %q of %q is not one of %s", v, p.label(), strings.Join(p.Values, ", "))
+		}
+	case typeString:
+		if p.Option == "" && p.Pattern == "" && strings.HasPrefix(v, "-") {
+			return "", invalid("value %q of %q starts with -", v, p.label())
+		}
+	case typeDuration:
+		_, err := time.ParseDuration(v)
+		if err != nil {
+			return "", invalid("value %q of %q is not a duration", v, p.label())
+		}
+	case typeFile, typeDirectory, typePath:
+		file, info, err := h.target(v, x)
+		if err != nil {
+			return "", &Failure{class: err, message: "value " + strconv.Quote(v) + " of " + strconv.Quote(p.label()) + ": " + err.Error()}
+		}
+
+		if p.Type == typeFile && info.IsDir() {
+			return "", &Failure{class: errTargetNotFile, message: "value " + strconv.Quote(v) + " of " + strconv.Quote(p.label()) + " is not a file"}
+		}
+
+		if p.Type == typeDirectory && !info.IsDir() {
+			return "", &Failure{class: errTargetNotDirectory, message: "value " + strconv.Quote(v) + " of " + strconv.Quote(p.label()) + " is not a directory"}
+		}
+
+		v = h.path(file, x)
+	}
+
+	if x.Shell {
+		return quote(v), nil
+	}
+
+	return v, nil
+}
+
+// assign spreads n positional values over the parameters in order, giving each
+// its least count first and the rest to the earliest parameters with room.
+func assign(p []*Parameter, n int) ([]int, error) {
+	counts := make([]int, len(p))
+	remaining := n
+
+	for i, v := range p {
+		least, _, err := v.cardinality()
+		if err != nil {
+			return nil, err
+		}
+
+		if remaining < least {
+			return nil, invalid("parameter %q is missing", v.label())
+		}
+
+		counts[i] = least
+		remaining -= least
+	}
+
+	for i, v := range p {
+		_, most, _ := v.cardinality()
+
+		extra := remaining
+		if most >= 0 && most-counts[i] < extra {
+			extra = most - counts[i]
+		}
+
+		counts[i] += extra
+		remaining -= extra
+	}
+
+	if remaining > 0 {
+		return nil, invalid("parameters are too many by %d", remaining)
+	}
+
+	return counts, nil
+}
+
+func invalid(f string, a ...any) error {
+	return &Failure{
+		class:   errArgumentsInvalid,
+		message: fmt.Sprintf(f, a...),
+	}
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
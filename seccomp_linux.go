@@ -0,0 +1,205 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	seccompKill  uint32 = 0x80000000
+	seccompErrno uint32 = 0x00050000
+	seccompAllow uint32 = 0x7fff0000
+)
+
+const (
+	ruleDeny uint8 = iota
+	ruleFlags
+	ruleDomain
+	rulePointer
+	ruleMissing
+)
+
+type Rule struct {
+	kind     uint8
+	syscall  uint32
+	argument uint32
+	value    uint32
+}
+
+var profiles map[string][]Rule = map[string][]Rule{
+	seccompReadOnly:  append(readOnly, Rule{kind: ruleDeny, syscall: unix.SYS_IO_URING_SETUP}),
+	seccompNoNetwork: append(noNetwork, Rule{kind: ruleDeny, syscall: unix.SYS_IO_URING_SETUP}),
+	seccompNoExec:    noExec,
+}
+
+func seccomp(p []string) error {
+	for _, v := range p {
+		_, ok := profiles[v]
+		if !ok {
+			return errSeccompInvalid
+		}
+	}
+
+	if len(p) > 0 && seccompArch == 0 {
+		return errSeccompUnsupported
+	}
+
+	return nil
+}
+
+func filter(p []string, e uintptr) ([]unix.SockFilter, error) {
+	err := seccomp(p)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := []unix.SockFilter{
+		load(4),
+		jump(unix.BPF_JEQ, seccompArch, 1, 0),
+		allow(seccompKill),
+		load(0),
+	}
+
+	if seccompMask != 0 {
+		instructions = append(instructions, jump(unix.BPF_JSET, seccompMask, 0, 1), allow(seccompKill))
+	}
+
+	for _, v := range p {
+		for _, r := range profiles[v] {
+			instructions = append(instructions, r.compile(e)...)
+		}
+	}
+
+	return append(instructions, allow(seccompAllow)), nil
+}
+
+func (r Rule) compile(e uintptr) []unix.SockFilter {
+	offset := 16 + 8*r.argument
+
+	switch r.kind {
+	case ruleFlags:
+		return []unix.SockFilter{
+			jump(unix.BPF_JEQ, r.syscall, 0, 4),
+			load(offset),
+			jump(unix.BPF_JSET, r.value, 0, 1),
+			allow(seccompKill),
+			load(0),
+		}
+	case ruleDomain:
+		return []unix.SockFilter{
+			jump(unix.BPF_JEQ, r.syscall, 0, 4),
+			load(offset),
+			jump(unix.BPF_JEQ, r.value, 1, 0),
+			allow(seccompKill),
+			load(0),
+		}
+	case rulePointer:
+		return []unix.SockFilter{
+			jump(unix.BPF_JEQ, r.syscall, 0, 6),
+			load(offset),
+			jump(unix.BPF_JEQ, uint32(e), 0, 2),
+			load(offset + 4),
+			jump(unix.BPF_JEQ, uint32(uint64(e)>>32), 1, 0),
+			allow(seccompKill),
+			load(0),
+		}
+	case ruleMissing:
+		return []unix.SockFilter{
+			jump(unix.BPF_JEQ, r.syscall, 0, 1),
+			allow(seccompErrno | uint32(unix.ENOSYS)),
+		}
+	default:
+		return []unix.SockFilter{
+			jump(unix.BPF_JEQ, r.syscall, 0, 1),
+			allow(seccompKill),
+		}
+	}
+}
+
+func load(k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: k}
+}
+
+func jump(c uint16, k uint32, t uint8, f uint8) unix.SockFilter {
+	return unix.SockFilter{Code: unix.BPF_JMP | c | unix.BPF_K, Jt: t, Jf: f, K: k}
+}
+
+func allow(k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: k}
+}
+
+// execute installs the filter and calls execve directly, because the filter
+// only lets execve through for the path pointer built here.
+func execute(p []string, path string, arguments []string, environment []string) error {
+	pointer, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+
+	argv, err := pointers(arguments)
+	if err != nil {
+		return err
+	}
+
+	envp, err := pointers(environment)
+	if err != nil {
+		return err
+	}
+
+	instructions, err := filter(p, uintptr(unsafe.Pointer(pointer)))
+	if err != nil {
+		return err
+	}
+
+	program := &unix.SockFprog{
+		Len:    uint16(len(instructions)),
+		Filter: &instructions[0],
+	}
+
+	err = unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(program)), 0, 0)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := unix.RawSyscall(unix.SYS_EXECVE, uintptr(unsafe.Pointer(pointer)), uintptr(unsafe.Pointer(&argv[0])), uintptr(unsafe.Pointer(&envp[0])))
+
+	runtime.KeepAlive(pointer)
+	runtime.KeepAlive(argv)
+	runtime.KeepAlive(envp)
+	return errno
+}
+
+func pointers(s []string) ([]*byte, error) {
+	pointers := make([]*byte, len(s)+1)
+	for i, v := range s {
+		pointer, err := unix.BytePtrFromString(v)
+		if err != nil {
+			return nil, err
+		}
+
+		pointers[i] = pointer
+	}
+
+	return pointers, nil
+}
@@ -0,0 +1,61 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import "golang.org/x/sys/unix"
+
+const (
+	seccompArch uint32 = unix.AUDIT_ARCH_AARCH64
+	seccompMask uint32 = 0
+)
+
+const openWrite uint32 = unix.O_WRONLY | unix.O_RDWR | unix.O_CREAT | unix.O_TRUNC | unix.O_APPEND
+
+var (
+	readOnly []Rule = []Rule{
+		{kind: ruleFlags, syscall: unix.SYS_OPENAT, argument: 2, value: openWrite},
+		{kind: ruleMissing, syscall: unix.SYS_OPENAT2},
+		{kind: ruleDeny, syscall: unix.SYS_UNLINKAT},
+		{kind: ruleDeny, syscall: unix.SYS_RENAMEAT},
+		{kind: ruleDeny, syscall: unix.SYS_RENAMEAT2},
+		{kind: ruleDeny, syscall: unix.SYS_MKDIRAT},
+		{kind: ruleDeny, syscall: unix.SYS_MKNODAT},
+		{kind: ruleDeny, syscall: unix.SYS_LINKAT},
+		{kind: ruleDeny, syscall: unix.SYS_SYMLINKAT},
+		{kind: ruleDeny, syscall: unix.SYS_FCHMOD},
+		{kind: ruleDeny, syscall: unix.SYS_FCHMODAT},
+		{kind: ruleDeny, syscall: unix.SYS_FCHMODAT2},
+		{kind: ruleDeny, syscall: unix.SYS_FCHOWN},
+		{kind: ruleDeny, syscall: unix.SYS_FCHOWNAT},
+		{kind: ruleDeny, syscall: unix.SYS_TRUNCATE},
+		{kind: ruleDeny, syscall: unix.SYS_FTRUNCATE},
+		{kind: ruleDeny, syscall: unix.SYS_UTIMENSAT},
+		{kind: ruleDeny, syscall: unix.SYS_SETXATTR},
+		{kind: ruleDeny, syscall: unix.SYS_LSETXATTR},
+		{kind: ruleDeny, syscall: unix.SYS_FSETXATTR},
+		{kind: ruleDeny, syscall: unix.SYS_REMOVEXATTR},
+		{kind: ruleDeny, syscall: unix.SYS_LREMOVEXATTR},
+		{kind: ruleDeny, syscall: unix.SYS_FREMOVEXATTR},
+	}
+
+	noNetwork []Rule = []Rule{
+		{kind: ruleDomain, syscall: unix.SYS_SOCKET, argument: 0, value: unix.AF_UNIX},
+	}
+
+	noExec []Rule = []Rule{
+		{kind: rulePointer, syscall: unix.SYS_EXECVE, argument: 0},
+		{kind: ruleDeny, syscall: unix.SYS_EXECVEAT},
+	}
+)
@@ -41,6 +41,11 @@ type Server struct {
 }
 
 func (s *Server) Run() error {
+	err := s.Handler.check()
+	if err != nil {
+		return err
+	}
+
 	server := &http.Server{
 		Handler:           s.Handler,
 		TLSConfig:         s.TLS,
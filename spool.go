@@ -0,0 +1,108 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+type Spool struct {
+	file    *os.File
+	size    int64
+	done    bool
+	changed chan struct{}
+	mutex   sync.Mutex
+}
+
+func spool(d string) (*Spool, error) {
+	file, err := os.CreateTemp(d, "httpsh-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Spool{
+		file:    file,
+		changed: make(chan struct{}),
+	}, nil
+}
+
+func (s *Spool) Write(b []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err := s.file.Write(b)
+	s.size += int64(n)
+	s.notify()
+
+	return n, err
+}
+
+func (s *Spool) finish() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.done = true
+	s.notify()
+}
+
+func (s *Spool) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Spool) copy(x context.Context, w io.Writer, o int64) error {
+	offset := o
+	buffer := make([]byte, 32*1024)
+
+	for {
+		s.mutex.Lock()
+		size := s.size
+		done := s.done
+		changed := s.changed
+		s.mutex.Unlock()
+
+		for offset < size {
+			n, err := s.file.ReadAt(buffer[:min(int64(len(buffer)), size-offset)], offset)
+			if err != nil && err != io.EOF {
+				return err
+			}
+
+			_, err = w.Write(buffer[:n])
+			if err != nil {
+				return err
+			}
+
+			offset += int64(n)
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-x.Done():
+			return x.Err()
+		}
+	}
+}
+
+func (s *Spool) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
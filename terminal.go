@@ -0,0 +1,235 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+var signals map[string]syscall.Signal = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"TERM":  syscall.SIGTERM,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"CONT":  syscall.SIGCONT,
+	"STOP":  syscall.SIGSTOP,
+	"TSTP":  syscall.SIGTSTP,
+	"WINCH": syscall.SIGWINCH,
+}
+
+type Message struct {
+	Type     string `json:"type"`
+	Columns  uint16 `json:"columns,omitempty"`
+	Rows     uint16 `json:"rows,omitempty"`
+	Signal   string `json:"signal,omitempty"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (h *Handler) terminal(response *Response, r *http.Request) {
+	err := handshake(r, h.Origins)
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	commands, err := h.stages(r.URL.Query())
+	if err != nil {
+		response.error(err)
+		return
+	}
+
+	if len(commands) != 1 {
+		response.error(errPipelineInvalid)
+		return
+	}
+
+	command := commands[0]
+	if !command.executable.Terminal {
+		response.error(errTerminalNotAllowed)
+		return
+	}
+
+	command.timeout = time.Duration(command.executable.Timeout) * time.Second
+
+	pipeline := h.pipeline(commands, nil)
+	pipeline.stdout.writer = io.Discard
+	pipeline.stdout.action = actionSpill
+	pipeline.stdout.capture = h.History != nil && h.History.Output
+
+	defer pipeline.close()
+
+	master, slave, err := pty()
+	if err != nil {
+		response.error(errTerminalUnavailable)
+		return
+	}
+
+	defer master.Close()
+
+	err = h.Pool.acquire(r.Context())
+	if err != nil {
+		slave.Close()
+		response.error(err)
+		return
+	}
+
+	defer h.Pool.release()
+
+	socket, err := upgrade(response.writer, r)
+	if err != nil {
+		slave.Close()
+		response.error(err)
+		return
+	}
+
+	context, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A session without input for the server timeout is ended, so idle
+	// terminals do not hold pool slots.
+	idle := time.Duration(h.Timeout) * time.Second
+	expired := atomic.Bool{}
+
+	command.terminal = slave
+	pipeline.errors = []error{command.start(context)}
+	slave.Close()
+
+	if pipeline.errors[0] == nil {
+		var timer *time.Timer
+		if idle > 0 {
+			timer = time.AfterFunc(idle, func() {
+				expired.Store(true)
+				cancel()
+			})
+
+			defer timer.Stop()
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			buffer := make([]byte, 32*1024)
+			for {
+				n, err := master.Read(buffer)
+				if n > 0 {
+					pipeline.stdout.Write(buffer[:n])
+					socket.write(opcodeBinary, buffer[:n])
+				}
+
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		go func() {
+			defer cancel()
+
+			for {
+				opcode, message, err := socket.read()
+				if err != nil {
+					return
+				}
+
+				if timer != nil {
+					timer.Reset(idle)
+				}
+
+				if opcode == opcodeBinary {
+					master.Write(message)
+					continue
+				}
+
+				control(master, command, message)
+			}
+		}()
+
+		pipeline.errors[0] = command.wait()
+		<-done
+
+		if expired.Load() {
+			pipeline.errors[0] = errCommandTimeout
+		}
+	}
+
+	codes, err := pipeline.result()
+
+	exit := &Message{
+		Type:     "exit",
+		ExitCode: codes[0],
+	}
+
+	signal, ok := killer(pipeline.errors[0])
+	if ok {
+		exit.Signal = signal.String()
+	}
+
+	if err != nil {
+		exit.Error = err.Error()
+	}
+
+	message, _ := json.Marshal(exit)
+
+	socket.write(opcodeText, message)
+	socket.close(1000, "")
+
+	h.record(identity(r), subject(r), "", pipeline, codes, err)
+}
+
+func control(m *os.File, c *Command, b []byte) {
+	message := &Message{}
+
+	err := json.Unmarshal(b, message)
+	if err != nil {
+		return
+	}
+
+	switch message.Type {
+	case "resize":
+		connection, err := m.SyscallConn()
+		if err != nil {
+			return
+		}
+
+		connection.Control(func(fd uintptr) {
+			unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{
+				Row: message.Rows,
+				Col: message.Columns,
+			})
+		})
+	case "signal":
+		signal, ok := signals[message.Signal]
+		if !ok {
+			return
+		}
+
+		syscall.Kill(-c.process.Process.Pid, signal)
+	}
+}
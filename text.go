@@ -0,0 +1,113 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var textClasses map[string]func(rune) bool = map[string]func(rune) bool{
+	"alpha":   unicode.IsLetter,
+	"digit":   unicode.IsDigit,
+	"lower":   unicode.IsLower,
+	"upper":   unicode.IsUpper,
+	"space":   unicode.IsSpace,
+	"punct":   func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) },
+	"print":   unicode.IsPrint,
+	"control": unicode.IsControl,
+}
+
+// Text enables t_, s_, and b_ arguments, which are refused unless Enabled is
+// set. Length is the largest decoded size in bytes, and every character must
+// belong to one of Classes when it is set. Without Classes any bytes but NUL
+// are allowed. Text never starts with "-", so it can not pass an option.
+type Text struct {
+	Enabled bool     `json:"enabled"`
+	Length  int      `json:"length,omitempty"`
+	Classes []string `json:"classes,omitempty"`
+}
+
+func (t *Text) check() error {
+	for _, v := range t.Classes {
+		_, ok := textClasses[v]
+		if !ok {
+			return errTextClassInvalid
+		}
+	}
+
+	return nil
+}
+
+func (t *Text) value(v string, encoded bool) (string, error) {
+	if !t.Enabled {
+		return "", text("text is not enabled for executable")
+	}
+
+	if encoded {
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+		if err != nil {
+			return "", text("text is not base64url")
+		}
+
+		v = string(decoded)
+	}
+
+	if t.Length > 0 && len(v) > t.Length {
+		return "", text("text is longer than %d bytes", t.Length)
+	}
+
+	if strings.ContainsRune(v, 0) {
+		return "", text("text contains a null byte")
+	}
+
+	if strings.HasPrefix(v, "-") {
+		return "", text("text starts with -")
+	}
+
+	if len(t.Classes) > 0 {
+		if !utf8.ValidString(v) {
+			return "", text("text is not valid utf-8")
+		}
+
+		for _, r := range v {
+			if !t.allowed(r) {
+				return "", text("text contains %q, which is not in %s", r, strings.Join(t.Classes, ", "))
+			}
+		}
+	}
+
+	return v, nil
+}
+
+func (t *Text) allowed(r rune) bool {
+	for _, v := range t.Classes {
+		if textClasses[v](r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func text(f string, a ...any) error {
+	return &Failure{
+		class:   errTextInvalid,
+		message: fmt.Sprintf(f, a...),
+	}
+}
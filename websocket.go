@@ -0,0 +1,237 @@
+// This file is part of httpsh.
+//
+// httpsh is free software: you can redistribute it and/or modify it under the
+// terms of the GNU General Public License as published by the Free Software
+// Foundation, either version 3 of the License, or (at your option) any later
+// version.
+//
+// httpsh is distributed in the hope that it will be useful, but WITHOUT ANY
+// WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR
+// A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along with
+// httpsh. If not, see <https://www.gnu.org/licenses/>.
+
+package httpsh
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	opcodeContinuation byte = 0x0
+	opcodeText         byte = 0x1
+	opcodeBinary       byte = 0x2
+	opcodeClose        byte = 0x8
+	opcodePing         byte = 0x9
+	opcodePong         byte = 0xa
+)
+
+const websocketGUID string = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const websocketMaximum int64 = 1 << 20
+
+type WebSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex
+}
+
+func handshake(r *http.Request, o []string) error {
+	if r.Method != http.MethodGet {
+		return errMethodNotAllowed
+	}
+
+	err := origin(r, o)
+	if err != nil {
+		return err
+	}
+
+	if !token(r.Header, "Connection", "upgrade") || !token(r.Header, "Upgrade", "websocket") {
+		return errWebSocketInvalid
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || r.Header.Get("Sec-WebSocket-Key") == "" {
+		return errWebSocketInvalid
+	}
+
+	return nil
+}
+
+// origin refuses cross-site handshakes. Browsers always send Origin, so a
+// request without it does not come from a web page. Otherwise the origin must
+// name the requested host or be one of the allowed origins.
+func origin(r *http.Request, o []string) error {
+	value := r.Header.Get("Origin")
+	if value == "" {
+		return nil
+	}
+
+	if slices.Contains(o, value) {
+		return nil
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" || !strings.EqualFold(parsed.Host, r.Host) {
+		return errOriginDenied
+	}
+
+	return nil
+}
+
+func upgrade(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	conn, buffer, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, errWebSocketUnsupported
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+
+	buffer.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	buffer.WriteString("Upgrade: websocket\r\n")
+	buffer.WriteString("Connection: Upgrade\r\n")
+	buffer.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+
+	err = buffer.Flush()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &WebSocket{
+		conn:   conn,
+		reader: buffer.Reader,
+	}, nil
+}
+
+func (w *WebSocket) read() (byte, []byte, error) {
+	opcode := byte(0)
+	message := []byte{}
+
+	for {
+		header := make([]byte, 2)
+
+		_, err := io.ReadFull(w.reader, header)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		final := header[0]&0x80 != 0
+		code := header[0] & 0x0f
+		length := int64(header[1] & 0x7f)
+
+		if header[1]&0x80 == 0 {
+			return 0, nil, errWebSocketInvalid
+		}
+
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+
+			_, err = io.ReadFull(w.reader, extended)
+			length = int64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+
+			_, err = io.ReadFull(w.reader, extended)
+			length = int64(binary.BigEndian.Uint64(extended) & (1<<63 - 1))
+		}
+
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if length > websocketMaximum || int64(len(message))+length > websocketMaximum {
+			return 0, nil, errWebSocketInvalid
+		}
+
+		mask := make([]byte, 4)
+
+		_, err = io.ReadFull(w.reader, mask)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		payload := make([]byte, length)
+
+		_, err = io.ReadFull(w.reader, payload)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch code {
+		case opcodeClose:
+			w.write(opcodeClose, payload[:min(len(payload), 2)])
+			return 0, nil, io.EOF
+		case opcodePing:
+			w.write(opcodePong, payload)
+			continue
+		case opcodePong:
+			continue
+		case opcodeContinuation:
+			message = append(message, payload...)
+		default:
+			opcode = code
+			message = payload
+		}
+
+		if final {
+			return opcode, message, nil
+		}
+	}
+}
+
+func (w *WebSocket) write(o byte, p []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	frame := []byte{0x80 | o}
+
+	switch {
+	case len(p) < 126:
+		frame = append(frame, byte(len(p)))
+	case len(p) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(p)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(p)))
+	}
+
+	_, err := w.conn.Write(append(frame, p...))
+	return err
+}
+
+func (w *WebSocket) close(c uint16, s string) error {
+	w.write(opcodeClose, append(binary.BigEndian.AppendUint16(nil, c), s...))
+	return w.conn.Close()
+}
+
+func token(h http.Header, k string, v string) bool {
+	for _, header := range h.Values(k) {
+		for _, value := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(value), v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}